@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	adminStateFile = "admin.json"
+
+	broadcastRate     = 25              // сообщений в секунду, ниже лимита Telegram в 30
+	broadcastProgress = 5 * time.Second // как часто обновлять отчёт о рассылке
+)
+
+// adminCommands — команды, доступные только администраторам
+var adminCommands = []string{"stats", "ban", "unban", "broadcast", "maintenance", "provider", "deeplink"}
+
+// adminStore хранит состояние, которым управляют администраторы: блокировки,
+// режим обслуживания, отключённые провайдеры и список пользователей для рассылки
+type adminStore struct {
+	mu    sync.Mutex
+	state adminState
+
+	broadcasting atomic.Bool
+}
+
+type adminState struct {
+	BannedUsers       map[int64]bool `json:"banned_users"`
+	BannedChats       map[int64]bool `json:"banned_chats"`
+	Maintenance       bool           `json:"maintenance"`
+	MaintenanceNotice string         `json:"maintenance_notice,omitempty"`
+	DisabledProviders []string       `json:"disabled_providers"` // отключённые командой /provider
+	KnownUsers        map[int64]bool `json:"known_users"`        // пользователи, писавшие боту в личку
+}
+
+func newAdminStore() *adminStore {
+	a := &adminStore{}
+	if err := loadJSON(adminStateFile, &a.state); err != nil {
+		slog.Error("Не удалось загрузить состояние администрирования", "error", err)
+	}
+	if a.state.BannedUsers == nil {
+		a.state.BannedUsers = map[int64]bool{}
+	}
+	if a.state.BannedChats == nil {
+		a.state.BannedChats = map[int64]bool{}
+	}
+	if a.state.KnownUsers == nil {
+		a.state.KnownUsers = map[int64]bool{}
+	}
+	return a
+}
+
+// isAdmin сообщает, является ли пользователь администратором
+func isAdmin(userID int64) bool {
+	return currentConfig().admins[userID]
+}
+
+// isBanned сообщает, заблокирован ли пользователь или чат
+func (a *adminStore) isBanned(userID, chatID int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.BannedUsers[userID] || a.state.BannedChats[chatID]
+}
+
+// maintenance возвращает, включён ли режим обслуживания, и текст уведомления,
+// заданный администратором; пустой текст означает стандартное сообщение
+func (a *adminStore) maintenance() (bool, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state.Maintenance, a.state.MaintenanceNotice
+}
+
+// rememberUser добавляет пользователя в список получателей рассылки
+func (a *adminStore) rememberUser(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state.KnownUsers[userID] {
+		return
+	}
+	a.state.KnownUsers[userID] = true
+	a.saveLocked()
+}
+
+// forgetUser убирает пользователя, заблокировавшего бота, из рассылки
+func (a *adminStore) forgetUser(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.state.KnownUsers, userID)
+	a.saveLocked()
+}
+
+func (a *adminStore) saveLocked() {
+	if err := saveJSON(adminStateFile, &a.state); err != nil {
+		slog.Error("Не удалось сохранить состояние администрирования", "error", err)
+	}
+}
+
+// handleAdminCommand выполняет команду администратора
+func handleAdminCommand(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	args := strings.TrimSpace(message.CommandArguments())
+	lang := i18n.From(ctx)
+
+	var reply string
+	switch message.Command() {
+	case "stats":
+		sendStats(bot, lang, chatID, args)
+		return
+	case "ban":
+		reply = botAdmin.ban(lang, message, args, true)
+		dropRejectedJobs(ctx)
+	case "unban":
+		reply = botAdmin.ban(lang, message, args, false)
+	case "broadcast":
+		botAdmin.broadcast(ctx, bot, message, args)
+		return
+	case "maintenance":
+		reply = botAdmin.setMaintenance(lang, args)
+		dropRejectedJobs(ctx)
+	case "provider":
+		reply = botAdmin.setProvider(lang, args)
+	case "deeplink":
+		reply = deepLinkReply(ctx, bot.Self.UserName, args)
+	}
+
+	logging.From(ctx).Info("Команда администратора", "command", message.Command(), "args", args)
+	bot.Send(tgbotapi.NewMessage(chatID, reply))
+}
+
+// dropRejectedJobs снимает с очереди задачи, которые больше не были бы приняты:
+// задачи заблокированных пользователей и чатов, а в режиме обслуживания — все,
+// кроме задач администраторов
+func dropRejectedJobs(ctx context.Context) {
+	on, _ := botAdmin.maintenance()
+	n := downloadScheduler.drop(func(userID, chatID int64) bool {
+		return !isAdmin(userID) && (on || botAdmin.isBanned(userID, chatID))
+	})
+	if n > 0 {
+		logging.From(ctx).Info("Задачи сняты с очереди", "count", n)
+	}
+}
+
+// ban блокирует (или разблокирует) пользователя или чат. Цель — ID из аргумента
+// (отрицательный ID означает чат) или автор сообщения, на которое ответили командой.
+func (a *adminStore) ban(lang i18n.Lang, message *tgbotapi.Message, args string, banned bool) string {
+	command := "/" + message.Command()
+
+	var id int64
+	switch {
+	case args != "":
+		var err error
+		id, err = strconv.ParseInt(args, 10, 64)
+		if err != nil {
+			return lang.T("admin.bad_id", args)
+		}
+	case message.ReplyToMessage != nil && message.ReplyToMessage.From != nil:
+		id = message.ReplyToMessage.From.ID
+	default:
+		return lang.T("admin.ban.usage", command)
+	}
+
+	if banned && isAdmin(id) {
+		return lang.T("admin.ban.admin")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	target, set := "user", a.state.BannedUsers
+	if id < 0 {
+		target, set = "chat", a.state.BannedChats
+	}
+	if banned {
+		set[id] = true
+	} else {
+		delete(set, id)
+	}
+	a.saveLocked()
+
+	if banned {
+		return lang.T("admin.ban."+target, id)
+	}
+	return lang.T("admin.unban."+target, id)
+}
+
+// setMaintenance включает или выключает режим обслуживания: новые задачи
+// не принимаются, пользователи получают уведомление, текущие загрузки завершаются
+func (a *adminStore) setMaintenance(lang i18n.Lang, args string) string {
+	mode, notice, _ := strings.Cut(args, " ")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch mode {
+	case "on":
+		a.state.Maintenance = true
+		a.state.MaintenanceNotice = strings.TrimSpace(notice)
+		a.saveLocked()
+		if a.state.MaintenanceNotice == "" {
+			return lang.T("admin.maintenance.enabled", lang.T("maintenance"))
+		}
+		return lang.T("admin.maintenance.enabled", a.state.MaintenanceNotice)
+	case "off":
+		a.state.Maintenance = false
+		a.state.MaintenanceNotice = ""
+		a.saveLocked()
+		return lang.T("admin.maintenance.off")
+	case "":
+		if a.state.Maintenance {
+			return lang.T("admin.maintenance.on")
+		}
+		return lang.T("admin.maintenance.off")
+	default:
+		return lang.T("admin.maintenance.usage")
+	}
+}
+
+// setProvider включает или отключает провайдера скачивания; без аргументов
+// показывает список провайдеров и их состояние
+func (a *adminStore) setProvider(lang i18n.Lang, args string) string {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		var b strings.Builder
+		b.WriteString(lang.T("admin.provider.list"))
+		b.WriteString("\n")
+		for _, name := range downloader.ProviderNames() {
+			state := lang.T("admin.provider.state.enabled")
+			if !downloader.ProviderEnabled(name) {
+				state = lang.T("admin.provider.state.disabled")
+			}
+			fmt.Fprintf(&b, "• %s — %s\n", name, state)
+		}
+		b.WriteString("\n")
+		b.WriteString(lang.T("admin.provider.usage"))
+		return b.String()
+	}
+	if len(fields) != 2 || (fields[0] != "enable" && fields[0] != "disable") {
+		return lang.T("admin.provider.usage")
+	}
+
+	enabled := fields[0] == "enable"
+	name := fields[1]
+	if !slices.Contains(downloader.ProviderNames(), name) {
+		return lang.T("admin.provider.unknown", name, strings.Join(downloader.ProviderNames(), ", "))
+	}
+
+	a.mu.Lock()
+	disabled := slices.DeleteFunc(a.state.DisabledProviders, func(n string) bool { return n == name })
+	if !enabled {
+		disabled = append(disabled, name)
+	}
+	a.state.DisabledProviders = disabled
+	a.saveLocked()
+	a.mu.Unlock()
+
+	applyProviders()
+
+	switch {
+	case enabled && !downloader.ProviderEnabled(name):
+		return lang.T("admin.provider.config_disabled", name)
+	case enabled:
+		return lang.T("admin.provider.enabled", name)
+	default:
+		return lang.T("admin.provider.disabled", name)
+	}
+}
+
+// disabledProviders возвращает провайдеров, отключённых командой /provider
+func (a *adminStore) disabledProviders() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.state.DisabledProviders...)
+}
+
+// broadcast рассылает сообщение всем известным пользователям не быстрее broadcastRate
+// в секунду, периодически обновляя отчёт о ходе рассылки. Если команда отправлена
+// ответом на сообщение, это сообщение копируется, иначе рассылается текст аргумента.
+func (a *adminStore) broadcast(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string) {
+	chatID := message.Chat.ID
+	lang := i18n.From(ctx)
+	source := message.ReplyToMessage
+	if source == nil && text == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("admin.broadcast.usage")))
+		return
+	}
+	if !a.broadcasting.CompareAndSwap(false, true) {
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("admin.broadcast.running")))
+		return
+	}
+	defer a.broadcasting.Store(false)
+
+	a.mu.Lock()
+	recipients := make([]int64, 0, len(a.state.KnownUsers))
+	for id := range a.state.KnownUsers {
+		recipients = append(recipients, id)
+	}
+	a.mu.Unlock()
+	sort.Slice(recipients, func(i, j int) bool { return recipients[i] < recipients[j] })
+
+	logger := logging.From(ctx).With("stage", "broadcast")
+	logger.Info("Рассылка начата", "recipients", len(recipients))
+
+	statusMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, lang.T("admin.broadcast.started", len(recipients))))
+	sent, failed, blocked := 0, 0, 0
+	report := func(final bool) {
+		text := lang.T("admin.broadcast.progress", sent+failed+blocked, len(recipients), sent, failed, blocked)
+		if final {
+			text = lang.T("admin.broadcast.done") + "\n" + text
+		}
+		if statusMsg.MessageID != 0 {
+			bot.Send(tgbotapi.NewEditMessageText(chatID, statusMsg.MessageID, text))
+		}
+	}
+
+	ticker := time.NewTicker(time.Second / broadcastRate)
+	defer ticker.Stop()
+	lastReport := time.Now()
+
+	for _, userID := range recipients {
+		<-ticker.C
+
+		var msg tgbotapi.Chattable = tgbotapi.NewMessage(userID, text)
+		if source != nil {
+			msg = tgbotapi.NewCopyMessage(userID, chatID, source.MessageID)
+		}
+
+		err := sendWithRetry(bot, msg)
+		var apiErr *tgbotapi.Error
+		switch {
+		case err == nil:
+			sent++
+		case errors.As(err, &apiErr) && apiErr.Code == 403:
+			blocked++
+			a.forgetUser(userID)
+		default:
+			failed++
+			logger.Warn("Не удалось доставить сообщение рассылки", "recipient", userID, "error", err)
+		}
+
+		if time.Since(lastReport) >= broadcastProgress {
+			report(false)
+			lastReport = time.Now()
+		}
+	}
+
+	report(true)
+	logger.Info("Рассылка завершена", "sent", sent, "failed", failed, "blocked", blocked)
+}
+
+// sendWithRetry отправляет сообщение и один раз повторяет попытку, если Telegram
+// ответил 429 Too Many Requests с указанием времени ожидания
+func sendWithRetry(bot *tgbotapi.BotAPI, msg tgbotapi.Chattable) error {
+	_, err := bot.Send(msg)
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == 429 && apiErr.RetryAfter > 0 {
+		time.Sleep(time.Duration(apiErr.RetryAfter) * time.Second)
+		_, err = bot.Send(msg)
+	}
+	return err
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxUserJobs — сколько ссылок один пользователь может скачивать одновременно
+const maxUserJobs = 5
+
+// maxAlbumSize — ограничение Telegram на количество элементов в альбоме
+const maxAlbumSize = 10
+
+// errSend — видео скачано, но Telegram не принял его при отправке
+var errSend = errors.New("не удалось отправить видео")
+
+var (
+	userJobsMu sync.Mutex
+	userJobs   = map[int64]int{}
+)
+
+// reserveUserJobs резервирует до n загрузок для пользователя и возвращает,
+// сколько удалось зарезервировать с учётом maxUserJobs
+func reserveUserJobs(userID int64, n int) int {
+	userJobsMu.Lock()
+	defer userJobsMu.Unlock()
+
+	free := maxUserJobs - userJobs[userID]
+	if n > free {
+		n = free
+	}
+	if n <= 0 {
+		return 0
+	}
+	userJobs[userID] += n
+	return n
+}
+
+func releaseUserJobs(userID int64, n int) {
+	userJobsMu.Lock()
+	defer userJobsMu.Unlock()
+
+	userJobs[userID] -= n
+	if userJobs[userID] <= 0 {
+		delete(userJobs, userID)
+	}
+}
+
+type batchResult struct {
+	link   string
+	media  *downloader.Media // скачанный файл; nil, если видео есть в кэше
+	fileID string            // file_id из кэша или после отправки
+	kind   fileKind
+	meta   downloader.Metadata
+	err    error
+}
+
+// batchStatus показывает прогресс пакетной загрузки в одном служебном сообщении
+type batchStatus struct {
+	mu        sync.Mutex
+	logger    *slog.Logger
+	lang      i18n.Lang
+	bot       *tgbotapi.BotAPI
+	chatID    int64
+	messageID int
+	total     int
+	done      int
+	positions map[int]int
+	lastText  string
+}
+
+func newBatchStatus(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, total int) *batchStatus {
+	s := &batchStatus{
+		logger:    logging.From(ctx),
+		lang:      i18n.From(ctx),
+		bot:       bot,
+		chatID:    target.chatID,
+		total:     total,
+		positions: make(map[int]int),
+	}
+	s.lastText = s.render()
+	if m, err := target.sendText(bot, s.lastText); err == nil {
+		s.messageID = m.MessageID
+	}
+	return s
+}
+
+func (s *batchStatus) render() string {
+	text := s.lang.T("batch.progress", s.done, s.total)
+
+	best := 0
+	for _, pos := range s.positions {
+		if best == 0 || pos < best {
+			best = pos
+		}
+	}
+	if best > 0 {
+		text += "\n" + s.lang.T("queue.position", best)
+	}
+	return text
+}
+
+// update перерисовывает сообщение, если текст изменился; вызывается под s.mu
+func (s *batchStatus) update() {
+	text := s.render()
+	if text == s.lastText || s.messageID == 0 {
+		return
+	}
+	s.lastText = text
+	if _, err := s.bot.Request(tgbotapi.NewEditMessageText(s.chatID, s.messageID, text)); err != nil {
+		s.logger.Warn("Не удалось обновить статус пакетной загрузки", "error", err)
+	}
+}
+
+func (s *batchStatus) queued(i, pos int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions[i] = pos
+	s.update()
+}
+
+func (s *batchStatus) started(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.positions, i)
+	s.update()
+}
+
+func (s *batchStatus) finished() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done++
+	s.update()
+}
+
+func (s *batchStatus) delete() {
+	if s.messageID == 0 {
+		return
+	}
+	if _, err := s.bot.Request(tgbotapi.NewDeleteMessage(s.chatID, s.messageID)); err != nil {
+		s.logger.Warn("Не удалось удалить служебное сообщение", "message_id", s.messageID, "error", err)
+	}
+}
+
+// processBatch скачивает несколько ссылок через общий планировщик
+// и отправляет результаты альбомом в исходном порядке со сводкой
+func processBatch(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, userID int64, links []string) {
+	chatID := target.chatID
+	logging.From(ctx).Info("Пакетная загрузка", "links", len(links))
+	status := newBatchStatus(ctx, bot, target, len(links))
+	results := make([]batchResult, len(links))
+
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+			defer status.finished()
+
+			ctx := logging.With(ctx, "link", link)
+
+			if cached, ok := videoCache.get(target.cacheKey(link)); ok && target.useCache() {
+				metricCacheHits.Inc()
+				botStats.recordCached(linkPlatform(link), userID, chatID)
+				rateLimiter.record(userID, 0)
+				results[i] = batchResult{link: link, fileID: cached.fileID, kind: cached.kind, meta: cached.meta}
+				return
+			}
+
+			err := acquireSlot(ctx, userID, chatID, func(pos int) {
+				status.queued(i, pos)
+			})
+			if err != nil {
+				logging.From(ctx).Info("Задача снята с очереди", "reason", err)
+				results[i] = batchResult{link: link, err: err}
+				return
+			}
+			status.started(i)
+			start := time.Now()
+			media, err := downloadLink(ctx, link, userID)
+			downloadScheduler.release()
+			latency := time.Since(start)
+			if err == nil {
+				err = target.prepare(ctx, media)
+			}
+			botStats.recordJob(linkPlatform(link), userID, chatID, latency, err)
+
+			if err != nil {
+				logging.From(ctx).Error("Ошибка скачивания", "error", err)
+				results[i] = batchResult{link: link, err: err}
+				return
+			}
+
+			recordDownload(userID, media.Path)
+			results[i] = batchResult{link: link, media: media, kind: target.fileKind(media), meta: media.Meta}
+		}(i, link)
+	}
+	wg.Wait()
+
+	deliverBatch(ctx, bot, target, results)
+	status.delete()
+	target.sendText(bot, batchSummary(i18n.From(ctx), results))
+	go cleanupOldFiles(userID)
+}
+
+// deliverBatch отправляет скачанные видео альбомами по maxAlbumSize штук, сохраняя
+// порядок ссылок. Если альбом отправить не удалось, видео отправляются по одному.
+// Анимации, аудио и документы в альбом видео не добавить, они всегда отправляются по одному.
+func deliverBatch(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, results []batchResult) {
+	logger := logging.From(ctx).With("stage", "upload")
+
+	var ready []*batchResult
+	for i := range results {
+		if results[i].err == nil {
+			ready = append(ready, &results[i])
+		}
+	}
+
+	for len(ready) > 0 {
+		n := 0
+		for n < len(ready) && n < maxAlbumSize && ready[n].kind == fileVideo {
+			n++
+		}
+		n = max(n, 1)
+		chunk := ready[:n]
+		ready = ready[n:]
+
+		if len(chunk) > 1 {
+			err := sendAlbum(bot, target, chunk)
+			if err == nil {
+				continue
+			}
+			logger.Warn("Не удалось отправить альбом, отправляю по одному", "error", err)
+		}
+
+		for _, r := range chunk {
+			if err := sendBatchVideo(logging.With(ctx, "link", r.link), bot, target, r); err != nil {
+				r.err = fmt.Errorf("%w: %v", errSend, err)
+			}
+		}
+	}
+
+	delivered := false
+	for _, r := range results {
+		if r.err == nil && r.media != nil {
+			videoCache.put(target.cacheKey(r.link), r.fileID, r.kind, r.meta)
+		}
+		delivered = delivered || r.err == nil
+	}
+	if delivered {
+		target.deleteOriginal(ctx, bot)
+	}
+
+	for _, r := range results {
+		if r.media != nil {
+			if err := r.media.Remove(); err != nil {
+				logger.Warn("Не удалось удалить временный файл", "path", r.media.Path, "error", err)
+			}
+		}
+	}
+}
+
+// sendBatchVideo отправляет одно видео пакета: из кэша по file_id или загрузкой файла
+func sendBatchVideo(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, r *batchResult) error {
+	if r.media == nil {
+		return sendFileID(bot, target, r.link, cacheEntry{fileID: r.fileID, kind: r.kind, meta: r.meta})
+	}
+
+	fileID, err := uploadMedia(ctx, bot, target, r.media, target.captionParams(r.link, r.meta))
+	r.fileID = fileID
+	return err
+}
+
+// albumVideo — элемент альбома в формате Bot API
+type albumVideo struct {
+	Type              string `json:"type"`
+	Media             string `json:"media"`
+	Caption           string `json:"caption,omitempty"`
+	ParseMode         string `json:"parse_mode,omitempty"`
+	Thumbnail         string `json:"thumbnail,omitempty"`
+	Width             int    `json:"width,omitempty"`
+	Height            int    `json:"height,omitempty"`
+	Duration          int    `json:"duration,omitempty"`
+	SupportsStreaming bool   `json:"supports_streaming"`
+}
+
+// sendAlbum отправляет видео пакета одним альбомом. MediaGroupConfig из tgbotapi
+// не поддерживает message_thread_id, поэтому альбом собирается вручную:
+// файлы прикрепляются как attach://, видео из кэша передаются по file_id.
+func sendAlbum(bot *tgbotapi.BotAPI, target delivery, results []*batchResult) error {
+	media := make([]albumVideo, 0, len(results))
+	var files []tgbotapi.RequestFile
+	for i, r := range results {
+		caption := target.captionParams(r.link, r.meta)
+		video := albumVideo{Type: "video", Caption: caption["caption"], ParseMode: caption["parse_mode"], SupportsStreaming: true}
+		if r.media == nil {
+			video.Media = r.fileID
+		} else {
+			name := fmt.Sprintf("file-%d", i)
+			video.Media = "attach://" + name
+			info := downloader.ProbeVideo(r.media.Path)
+			video.Width, video.Height, video.Duration = info.Width, info.Height, info.Seconds()
+			files = append(files, tgbotapi.RequestFile{Name: name, Data: tgbotapi.FilePath(r.media.Path)})
+			if r.media.Thumbnail != "" {
+				video.Thumbnail = "attach://" + name + "-thumbnail"
+				files = append(files, tgbotapi.RequestFile{Name: name + "-thumbnail", Data: tgbotapi.FilePath(r.media.Thumbnail)})
+			}
+		}
+		media = append(media, video)
+	}
+
+	params := target.params()
+	if err := params.AddInterface("media", media); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var messages []tgbotapi.Message
+	err := sendRequest(bot, "sendMediaGroup", params, files, &messages)
+	metricUploadDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.media != nil {
+			metricBytes.WithLabelValues("upload").Add(float64(fileSize(r.media.Path)))
+		}
+	}
+	for i, msg := range messages {
+		if i < len(results) {
+			results[i].fileID = messageFileID(msg)
+		}
+	}
+	return nil
+}
+
+// batchSummary формирует итоговое сообщение пакетной загрузки
+func batchSummary(lang i18n.Lang, results []batchResult) string {
+	succeeded := 0
+	var failed []string
+	for _, r := range results {
+		if r.err == nil {
+			succeeded++
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("• %s — %s", r.link, errorText(lang, r.err)))
+	}
+
+	summary := lang.T("batch.summary", succeeded, len(results))
+	if len(failed) > 0 {
+		summary += "\n\n" + lang.T("batch.failed") + "\n" + strings.Join(failed, "\n")
+	}
+	return summary
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"goland/VideoSaverBot/downloader"
+	"sync"
+	"time"
+)
+
+// fileCacheTTL — сколько хранится file_id отправленного видео
+const fileCacheTTL = 24 * time.Hour
+
+// fileCache запоминает file_id отправленных видео и сведения о посте по ссылке,
+// чтобы повторный запрос той же ссылки отправлялся без скачивания
+type fileCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	fileID  string
+	kind    fileKind            // метод повторной отправки по file_id
+	meta    downloader.Metadata // для подписи при повторной отправке
+	expires time.Time
+}
+
+var videoCache = &fileCache{entries: make(map[string]cacheEntry)}
+
+func (c *fileCache) get(link string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[link]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, link)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *fileCache) put(link, fileID string, kind fileKind, meta downloader.Metadata) {
+	if fileID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[link] = cacheEntry{fileID: fileID, kind: kind, meta: meta, expires: now.Add(fileCacheTTL)}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"html"
+	"strings"
+	"unicode/utf16"
+)
+
+// captionLimit — ограничение Telegram на длину подписи: считаются символы
+// после разбора разметки в единицах UTF-16
+const captionLimit = 1024
+
+// maxAuthorLen — ограничение на имя автора в подписи
+const maxAuthorLen = 64
+
+// Форматы разметки подписи
+const (
+	captionHTML       = "html"
+	captionMarkdownV2 = "markdownv2"
+)
+
+// parseModes — значение parse_mode Bot API для формата подписи
+var parseModes = map[string]string{
+	captionHTML:       "HTML",
+	captionMarkdownV2: "MarkdownV2",
+}
+
+// captionSpan — фрагмент подписи; текст хранится без экранирования
+type captionSpan struct {
+	text string
+	bold bool
+	url  string // фрагмент — ссылка на url
+}
+
+// captionText собирает подпись из фрагментов и экранирует её для формата format
+type captionText struct {
+	format string
+	spans  []captionSpan
+}
+
+func (c *captionText) add(span captionSpan) {
+	c.spans = append(c.spans, span)
+}
+
+// visibleLen — длина подписи, которую увидит Telegram после разбора разметки
+func (c *captionText) visibleLen() int {
+	n := 0
+	for _, span := range c.spans {
+		n += utf16Len(span.text)
+	}
+	return n
+}
+
+func (c *captionText) String() string {
+	var b strings.Builder
+	for _, span := range c.spans {
+		text := escapeCaption(c.format, span.text)
+		switch {
+		case span.url != "" && c.format == captionHTML:
+			b.WriteString(`<a href="` + html.EscapeString(span.url) + `">` + text + `</a>`)
+		case span.url != "":
+			b.WriteString("[" + text + "](" + markdownV2URL.Replace(span.url) + ")")
+		case span.bold && c.format == captionHTML:
+			b.WriteString("<b>" + text + "</b>")
+		case span.bold:
+			b.WriteString("*" + text + "*")
+		default:
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// markdownV2 экранирует все служебные символы MarkdownV2 в тексте
+var markdownV2 = strings.NewReplacer(
+	`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`,
+	`~`, `\~`, "`", "\\`", `>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`,
+	`|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+)
+
+// markdownV2URL экранирует адрес внутри (...) ссылки MarkdownV2
+var markdownV2URL = strings.NewReplacer(`\`, `\\`, `)`, `\)`)
+
+func escapeCaption(format, text string) string {
+	if format == captionHTML {
+		return html.EscapeString(text)
+	}
+	return markdownV2.Replace(text)
+}
+
+// buildCaption формирует подпись к видео в стиле style:
+//
+//	Автор (@handle)
+//	Описание поста, обрезанное до maxDescription символов…
+//
+//	TikTok · Оригинал · Прислал(а): @user
+//
+// В стиле link остаётся только последняя строка без автора запроса. Описание
+// обрезается так, чтобы подпись уложилась в captionLimit.
+func buildCaption(lang i18n.Lang, style, format string, maxDescription int, link string, platform downloader.PlatformType, meta downloader.Metadata, requester string) string {
+	if style == captionNone {
+		return ""
+	}
+
+	footer := captionText{format: format}
+	if title, ok := platformTitles[platform]; ok {
+		footer.add(captionSpan{text: title + " · "})
+	}
+	footer.add(captionSpan{text: lang.T("caption.source"), url: link})
+	if style == captionLink {
+		return footer.String()
+	}
+	if requester != "" {
+		footer.add(captionSpan{text: " · " + lang.T("caption.requester", requester)})
+	}
+
+	header := captionText{format: format}
+	meta.Author = truncateText(meta.Author, maxAuthorLen)
+	switch {
+	case meta.Author != "" && meta.Handle != "":
+		header.add(captionSpan{text: meta.Author, bold: true})
+		header.add(captionSpan{text: " (@" + meta.Handle + ")"})
+	case meta.Author != "":
+		header.add(captionSpan{text: meta.Author, bold: true})
+	case meta.Handle != "":
+		header.add(captionSpan{text: "@" + meta.Handle, bold: true})
+	}
+	if header.visibleLen() > 0 {
+		header.add(captionSpan{text: "\n"})
+	}
+
+	// Описание получает место, оставшееся от остальных частей подписи
+	room := captionLimit - header.visibleLen() - footer.visibleLen() - utf16Len("\n\n")
+	description := truncateText(meta.Description, min(maxDescription, room))
+	if description != "" {
+		header.add(captionSpan{text: description + "\n"})
+	}
+	if header.visibleLen() == 0 {
+		return footer.String()
+	}
+	return header.String() + "\n" + footer.String()
+}
+
+// truncateText обрезает текст до limit единиц UTF-16, добавляя многоточие
+func truncateText(text string, limit int) string {
+	if utf16Len(text) <= limit {
+		return text
+	}
+	if limit <= 1 {
+		return ""
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range text {
+		size := runeLen16(r)
+		if n+size > limit-1 {
+			break
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return strings.TrimRight(b.String(), " \n") + "…"
+}
+
+func utf16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += runeLen16(r)
+	}
+	return n
+}
+
+// runeLen16 — сколько единиц UTF-16 занимает символ
+func runeLen16(r rune) int {
+	if utf16.IsSurrogate(r) || r < 0x10000 {
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const chatSettingsStateFile = "chat_settings.json"
+
+// settingsCallbackPrefix — префикс callback_data кнопок клавиатуры /settings
+const settingsCallbackPrefix = "settings:"
+
+// Стили подписи к отправленному видео
+const (
+	captionNone = "none" // без подписи
+	captionLink = "link" // платформа и ссылка на оригинал
+	captionFull = "full" // автор, описание поста, ссылка и автор запроса
+)
+
+var captionStyles = []string{captionNone, captionLink, captionFull}
+
+// Режимы поиска ссылок в группе без упоминания бота
+const (
+	linkModeBare = "bare" // сообщение целиком состоит из одной ссылки
+	linkModeAny  = "any"  // ссылка в любом месте текста, подписи или сущностей
+)
+
+var linkModes = []string{linkModeBare, linkModeAny}
+
+// durationLimits — варианты ограничения длины видео в секундах; 0 — без ограничения
+var durationLimits = []int{0, 60, 180, 600}
+
+// platformOrder — порядок платформ на клавиатуре настроек
+var platformOrder = []downloader.PlatformType{
+	downloader.Instagram,
+	downloader.Twitter,
+	downloader.TikTok,
+	downloader.Facebook,
+	downloader.YouTube,
+}
+
+var platformTitles = map[downloader.PlatformType]string{
+	downloader.Instagram: "Instagram",
+	downloader.Twitter:   "Twitter/X",
+	downloader.TikTok:    "TikTok",
+	downloader.Facebook:  "Facebook",
+	downloader.YouTube:   "YouTube",
+}
+
+// errTooLong возвращается, если видео длиннее, чем разрешено настройками группы
+var errTooLong = errors.New("видео длиннее разрешённого в группе")
+
+// chatSettings — настройки поведения бота в группе
+type chatSettings struct {
+	AutoDownload      bool                      `json:"auto_download"`                // скачивать ссылки без упоминания бота
+	LinkMode          string                    `json:"link_mode"`                    // какие ссылки скачивать без упоминания: bare или any
+	DisabledPlatforms []downloader.PlatformType `json:"disabled_platforms,omitempty"` // платформы, ссылки на которые игнорируются
+	Caption           string                    `json:"caption,omitempty"`            // стиль подписи: none, link или full; пусто — из конфигурации
+	Reply             bool                      `json:"reply"`                        // отвечать на сообщение со ссылкой результатом, ошибками и прогрессом
+	DeleteOriginal    bool                      `json:"delete_original"`              // удалять сообщение со ссылкой после отправки
+	AudioOnly         bool                      `json:"audio_only"`                   // отправлять только звуковую дорожку
+	MaxDuration       int                       `json:"max_duration"`                 // максимальная длина видео в секундах, 0 — без ограничения
+}
+
+// defaultChatSettings возвращает настройки группы, которая их не меняла
+func defaultChatSettings() chatSettings {
+	return chatSettings{AutoDownload: true, LinkMode: linkModeBare, Reply: true}
+}
+
+// captionStyle возвращает стиль подписи; группы, не выбравшие стиль,
+// используют captions.style из конфигурации
+func (s chatSettings) captionStyle() string {
+	if s.Caption == "" {
+		return currentConfig().Captions.Style
+	}
+	return s.Caption
+}
+
+// platformAllowed сообщает, скачивает ли бот ссылки на платформу в этом чате
+func (s chatSettings) platformAllowed(platform downloader.PlatformType) bool {
+	return !slices.Contains(s.DisabledPlatforms, platform)
+}
+
+// chatSettingsStore хранит настройки групп, изменённые командой /settings
+type chatSettingsStore struct {
+	mu    sync.Mutex
+	chats map[int64]chatSettings
+}
+
+func newChatSettingsStore() *chatSettingsStore {
+	s := &chatSettingsStore{chats: map[int64]chatSettings{}}
+	if err := loadJSON(chatSettingsStateFile, &s.chats); err != nil {
+		slog.Error("Не удалось загрузить настройки групп", "error", err)
+	}
+	// Параметры, появившиеся позже, получают значения по умолчанию
+	defaults := defaultChatSettings()
+	for id, settings := range s.chats {
+		if settings.LinkMode == "" {
+			settings.LinkMode = defaults.LinkMode
+		}
+		s.chats[id] = settings
+	}
+	return s
+}
+
+// get возвращает настройки чата; для чатов без настроек — значения по умолчанию
+func (s *chatSettingsStore) get(chatID int64) chatSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	settings, ok := s.chats[chatID]
+	if !ok {
+		return defaultChatSettings()
+	}
+	settings.DisabledPlatforms = slices.Clone(settings.DisabledPlatforms)
+	return settings
+}
+
+// update изменяет настройки чата и сохраняет их
+func (s *chatSettingsStore) update(chatID int64, change func(*chatSettings)) chatSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.chats[chatID]
+	if !ok {
+		settings = defaultChatSettings()
+	}
+	change(&settings)
+	s.chats[chatID] = settings
+
+	if err := saveJSON(chatSettingsStateFile, s.chats); err != nil {
+		slog.Error("Не удалось сохранить настройки групп", "error", err)
+	}
+	settings.DisabledPlatforms = slices.Clone(settings.DisabledPlatforms)
+	return settings
+}
+
+// handleSettingsCommand показывает клавиатуру настроек группы её администраторам
+func handleSettingsCommand(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	lang := i18n.From(ctx)
+
+	if message.Chat.IsPrivate() {
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("settings.groups_only")))
+		return
+	}
+	if !isAdmin(message.From.ID) && !isChatAdmin(ctx, bot, chatID, message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("settings.chat_admin")))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, lang.T("settings.title"))
+	msg.ReplyMarkup = settingsKeyboard(lang, groupSettings.get(chatID))
+	bot.Send(msg)
+}
+
+// handleSettingsCallback меняет настройку по нажатию кнопки и перерисовывает клавиатуру.
+// Права проверяются заново: клавиатуру видят все участники группы.
+func handleSettingsCallback(ctx context.Context, bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	lang := i18n.From(ctx)
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	if !isAdmin(query.From.ID) && !isChatAdmin(ctx, bot, chatID, query.From.ID) {
+		answer := tgbotapi.NewCallbackWithAlert(query.ID, lang.T("settings.chat_admin"))
+		bot.Request(answer)
+		return
+	}
+
+	action := strings.TrimPrefix(query.Data, settingsCallbackPrefix)
+	if action == "close" {
+		bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+		return
+	}
+
+	settings := groupSettings.update(chatID, func(s *chatSettings) {
+		switch {
+		case action == "auto":
+			s.AutoDownload = !s.AutoDownload
+		case action == "links":
+			s.LinkMode = nextOption(linkModes, s.LinkMode)
+		case action == "caption":
+			s.Caption = nextOption(captionStyles, s.captionStyle())
+		case action == "reply":
+			s.Reply = !s.Reply
+		case action == "delete":
+			s.DeleteOriginal = !s.DeleteOriginal
+		case action == "audio":
+			s.AudioOnly = !s.AudioOnly
+		case action == "duration":
+			s.MaxDuration = nextOption(durationLimits, s.MaxDuration)
+		case strings.HasPrefix(action, "platform:"):
+			platform := downloader.PlatformType(strings.TrimPrefix(action, "platform:"))
+			if !s.platformAllowed(platform) {
+				s.DisabledPlatforms = slices.DeleteFunc(s.DisabledPlatforms, func(p downloader.PlatformType) bool { return p == platform })
+			} else if _, ok := platformTitles[platform]; ok {
+				s.DisabledPlatforms = append(s.DisabledPlatforms, platform)
+			}
+		}
+	})
+	logging.From(ctx).Info("Настройки группы изменены", "action", action)
+
+	bot.Request(tgbotapi.NewCallback(query.ID, ""))
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, settingsKeyboard(lang, settings))
+	if _, err := bot.Request(edit); err != nil {
+		logging.From(ctx).Warn("Не удалось обновить клавиатуру настроек", "error", err)
+	}
+}
+
+// nextOption возвращает следующий по кругу вариант настройки
+func nextOption[T comparable](options []T, current T) T {
+	i := slices.Index(options, current)
+	return options[(i+1)%len(options)]
+}
+
+// settingsKeyboard строит клавиатуру с текущими значениями настроек
+func settingsKeyboard(lang i18n.Lang, s chatSettings) tgbotapi.InlineKeyboardMarkup {
+	button := func(text, action string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, settingsCallbackPrefix+action)
+	}
+	onOff := func(on bool) string {
+		if on {
+			return lang.T("settings.on")
+		}
+		return lang.T("settings.off")
+	}
+
+	var platforms []tgbotapi.InlineKeyboardButton
+	for _, platform := range platformOrder {
+		mark := "✅ "
+		if !s.platformAllowed(platform) {
+			mark = "❌ "
+		}
+		platforms = append(platforms, button(mark+platformTitles[platform], "platform:"+string(platform)))
+	}
+
+	maxDuration := lang.T("settings.duration.none")
+	if s.MaxDuration > 0 {
+		maxDuration = formatWait(lang, time.Duration(s.MaxDuration)*time.Second)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.auto", onOff(s.AutoDownload)), "auto")),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.links", lang.T("settings.links."+s.LinkMode)), "links")),
+		tgbotapi.NewInlineKeyboardRow(platforms[:3]...),
+		tgbotapi.NewInlineKeyboardRow(platforms[3:]...),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.caption", lang.T("settings.caption."+s.captionStyle())), "caption")),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.reply", onOff(s.Reply)), "reply")),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.delete", onOff(s.DeleteOriginal)), "delete")),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.audio", onOff(s.AudioOnly)), "audio")),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.duration", maxDuration), "duration")),
+		tgbotapi.NewInlineKeyboardRow(button(lang.T("settings.close"), "close")),
+	)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errClipRange = errors.New("некорректный интервал")
+
+// clipRangeError — интервал /clip начинается после конца видео
+type clipRangeError struct {
+	start, duration time.Duration
+}
+
+func (e *clipRangeError) Error() string {
+	return fmt.Sprintf("начало фрагмента %s после конца видео %s", e.start, e.duration)
+}
+
+// clipMessage возвращает текст ошибки интервала с длиной видео
+func clipMessage(lang i18n.Lang, err *clipRangeError) string {
+	return lang.T("clip.out_of_range", formatTimestamp(err.duration))
+}
+
+// parseClipRange находит в аргументах /clip интервал вида <начало>-<конец>,
+// например 1:05-1:20 или 12.5-20
+func parseClipRange(args string) (start, end time.Duration, err error) {
+	for _, arg := range strings.Fields(args) {
+		from, to, ok := strings.Cut(arg, "-")
+		if !ok || strings.Contains(arg, "/") {
+			continue
+		}
+		if start, err = parseTimestamp(from); err != nil {
+			return 0, 0, err
+		}
+		if end, err = parseTimestamp(to); err != nil {
+			return 0, 0, err
+		}
+		if end <= start {
+			return 0, 0, fmt.Errorf("%w: конец %s не позже начала %s", errClipRange, to, from)
+		}
+		return start, end, nil
+	}
+	return 0, 0, fmt.Errorf("%w: интервал не указан", errClipRange)
+}
+
+// parseTimestamp разбирает момент видео в форматах с, м:с или ч:м:с; секунды могут быть дробными
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("%w: %q", errClipRange, s)
+	}
+
+	var total float64
+	for i, part := range parts {
+		last := i == len(parts)-1
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || strings.Trim(part, "0123456789.") != "" || (!last && value != float64(int(value))) || (i > 0 && value >= 60) {
+			return 0, fmt.Errorf("%w: %q", errClipRange, s)
+		}
+		total = total*60 + value
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// formatTimestamp форматирует момент видео как м:сс или ч:мм:сс
+func formatTimestamp(d time.Duration) string {
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// clipConversion возвращает преобразование для /clip: фрагмент видео от start до end.
+// Интервал проверяется по длине скачанного видео; конец за пределами видео обрезается.
+func clipConversion(start, end time.Duration) conversion {
+	return conversion{
+		name:   fmt.Sprintf("clip-%d-%d", start.Milliseconds(), end.Milliseconds()),
+		stages: downloader.Pipeline{clipStartStage(start), downloader.Trim(start, end)},
+	}
+}
+
+// clipStartStage — шаг, который проверяет, что фрагмент начинается до конца видео
+func clipStartStage(start time.Duration) downloader.Stage {
+	return downloader.Stage{Name: "clip_range", Run: func(ctx context.Context, job *downloader.Job) error {
+		if duration := downloader.ProbeVideo(job.Media.Path).Duration; duration > 0 && start >= duration {
+			return &clipRangeError{start: start, duration: duration}
+		}
+		return nil
+	}}
+}
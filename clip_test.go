@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseClipRange(t *testing.T) {
+	tests := []struct {
+		args       string
+		start, end time.Duration
+		wantErr    bool
+	}{
+		{args: "1:05-1:20", start: 65 * time.Second, end: 80 * time.Second},
+		{args: "12.5-20", start: 12500 * time.Millisecond, end: 20 * time.Second},
+		{args: "0-1:00:00", end: time.Hour},
+		{args: "https://x.com/a-b/status/1 3-7", start: 3 * time.Second, end: 7 * time.Second},
+		{args: "5-5", wantErr: true},
+		{args: "10-5", wantErr: true},
+		{args: "1:60-2:00", wantErr: true},
+		{args: "0-1:60", wantErr: true},
+		{args: "-5", wantErr: true},
+		{args: "5-", wantErr: true},
+		{args: "-", wantErr: true},
+		{args: "", wantErr: true},
+		{args: "https://x.com/a-b", wantErr: true},
+		{args: "1.5:10-2:00", wantErr: true},
+		{args: "1:2:3:4-5", wantErr: true},
+		{args: "+5-10", wantErr: true},
+		{args: "1e1-20", wantErr: true},
+		{args: "abc-def", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			start, end, err := parseClipRange(tt.args)
+			if tt.wantErr {
+				if !errors.Is(err, errClipRange) {
+					t.Errorf("parseClipRange(%q) error = %v, want errClipRange", tt.args, err)
+				}
+				return
+			}
+			if err != nil || start != tt.start || end != tt.end {
+				t.Errorf("parseClipRange(%q) = %v, %v, %v, want %v, %v", tt.args, start, end, err, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "90", want: 90 * time.Second},
+		{in: "1:30", want: 90 * time.Second},
+		{in: "1:02:03.5", want: time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{in: "0:59.9", want: 59900 * time.Millisecond},
+		{in: "1:60", wantErr: true},
+		{in: "1:2:60", wantErr: true},
+		{in: "", wantErr: true},
+		{in: ":30", wantErr: true},
+		{in: "1:", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "1.5:00", wantErr: true},
+		{in: "0x10", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseTimestamp(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTimestamp(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseTimestamp(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{59600 * time.Millisecond, "1:00"},
+		{65 * time.Second, "1:05"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1:02:03"},
+	}
+	for _, tt := range tests {
+		if got := formatTimestamp(tt.in); got != tt.want {
+			t.Errorf("formatTimestamp(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
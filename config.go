@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"io"
+	"log/slog"
+	neturl "net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigPath = "config.yaml"
+
+// Config — все настройки бота. Источники по возрастанию приоритета: значения
+// по умолчанию, YAML-файл, переменные окружения, явно заданные флаги.
+type Config struct {
+	Token   string        `yaml:"token"`
+	Debug   bool          `yaml:"debug"`
+	DataDir string        `yaml:"data_dir"`
+	Webhook webhookConfig `yaml:"webhook"`
+	Metrics string        `yaml:"metrics"`
+	Log     logConfig     `yaml:"log"`
+
+	Downloads downloadsConfig `yaml:"downloads"`
+	Cleanup   cleanupConfig   `yaml:"cleanup"`
+	Limits    limitsConfig    `yaml:"limits"`
+	Providers providersConfig `yaml:"providers"`
+	Captions  captionsConfig  `yaml:"captions"`
+	Inline    inlineConfig    `yaml:"inline"`
+	DeepLinks deepLinksConfig `yaml:"deep_links"`
+
+	// Messages переопределяет тексты каталога i18n: язык → ключ → текст
+	Messages map[string]map[string]string `yaml:"messages"`
+
+	Admins        []int64 `yaml:"admins"`
+	PriorityUsers []int64 `yaml:"priority_users"`
+	ExemptUsers   []int64 `yaml:"exempt_users"`
+	ExemptChats   []int64 `yaml:"exempt_chats"`
+
+	// Множества ID, собранные из списков выше при загрузке
+	admins        map[int64]bool
+	priorityUsers map[int64]bool
+	exemptUsers   map[int64]bool
+	exemptChats   map[int64]bool
+}
+
+type logConfig struct {
+	Level string `yaml:"level"`
+	JSON  bool   `yaml:"json"`
+}
+
+type downloadsConfig struct {
+	Concurrent    int           `yaml:"concurrent"`
+	FFmpegWorkers int           `yaml:"ffmpeg_workers"` // одновременных процессов ffmpeg, отдельно от concurrent
+	Timeout       time.Duration `yaml:"timeout"`        // на всю цепочку провайдеров
+	MaxFileMB     int64         `yaml:"max_file_mb"`    // лимит Bot API на отправку — 50 MB
+	UserAgent     string        `yaml:"user_agent"`
+}
+
+type cleanupConfig struct {
+	Interval     time.Duration `yaml:"interval"`       // период общей очистки временных файлов
+	UserFilesAge time.Duration `yaml:"user_files_age"` // возраст файлов пользователя, удаляемых после загрузки
+	TempFilesAge time.Duration `yaml:"temp_files_age"` // возраст файлов, удаляемых общей очисткой
+}
+
+type limitsConfig struct {
+	UserRate       int   `yaml:"user_rate"`
+	ChatRate       int   `yaml:"chat_rate"`
+	DailyDownloads int   `yaml:"daily_downloads"`
+	DailyMB        int64 `yaml:"daily_mb"`
+}
+
+type providersConfig struct {
+	Disabled  []string        `yaml:"disabled"`
+	Endpoints endpointsConfig `yaml:"endpoints"`
+}
+
+type captionsConfig struct {
+	Style             string `yaml:"style"`              // none, link или full: для личных чатов и групп, не выбравших стиль в /settings
+	Format            string `yaml:"format"`             // разметка подписи: html или markdownv2
+	DescriptionLength int    `yaml:"description_length"` // максимум символов описания поста
+	Metadata          bool   `yaml:"metadata"`           // запрашивать автора и описание поста
+}
+
+type inlineConfig struct {
+	StorageChat int64         `yaml:"storage_chat"` // чат, куда загружаются видео для inline-ответов; 0 — только из кэша
+	Wait        time.Duration `yaml:"wait"`         // сколько ждать скачивания до ответа на inline-запрос
+}
+
+type deepLinksConfig struct {
+	Secret string `yaml:"secret"` // ключ подписи payload /start; пусто — выводится из токена
+}
+
+type endpointsConfig struct {
+	Snapsave          string `yaml:"snapsave"`
+	TwitterDownloader string `yaml:"twitterdownloader"`
+	Snaptik           string `yaml:"snaptik"`
+	Tikmate           string `yaml:"tikmate"`
+	DDInstagram       string `yaml:"ddinstagram"` // домен, а не URL
+	VXTwitter         string `yaml:"vxtwitter"`   // домен, а не URL
+}
+
+// defaultConfig возвращает настройки по умолчанию
+func defaultConfig() *Config {
+	dl := downloader.DefaultSettings()
+	return &Config{
+		DataDir: "data",
+		Webhook: webhookConfig{Listen: ":8443"},
+		Log:     logConfig{Level: "info"},
+		Downloads: downloadsConfig{
+			Concurrent:    5,
+			FFmpegWorkers: dl.FFmpegWorkers,
+			Timeout:       3 * time.Minute,
+			MaxFileMB:     dl.MaxFileSize / (1024 * 1024),
+			UserAgent:     dl.UserAgent,
+		},
+		Cleanup: cleanupConfig{
+			Interval:     time.Hour,
+			UserFilesAge: time.Hour,
+			TempFilesAge: 24 * time.Hour,
+		},
+		Limits: limitsConfig{
+			UserRate:       10,
+			ChatRate:       30,
+			DailyDownloads: 100,
+			DailyMB:        2000,
+		},
+		Providers: providersConfig{
+			Endpoints: endpointsConfig{
+				Snapsave:          dl.SnapsaveURL,
+				TwitterDownloader: dl.TwitterDownloaderURL,
+				Snaptik:           dl.SnaptikURL,
+				Tikmate:           dl.TikmateURL,
+				DDInstagram:       dl.DDInstagramHost,
+				VXTwitter:         dl.VXTwitterHost,
+			},
+		},
+		Captions: captionsConfig{
+			Style:             captionFull,
+			Format:            captionHTML,
+			DescriptionLength: 200,
+			Metadata:          dl.FetchMetadata,
+		},
+		Inline: inlineConfig{Wait: 8 * time.Second},
+	}
+}
+
+// configFlags применяют явно заданные флаги командной строки к загруженной конфигурации
+var configFlags []func(c *Config, set map[string]bool)
+
+// bindFlag регистрирует флаг, который переопределяет поле конфигурации,
+// только если указан явно; значением по умолчанию в справке служит значение конфигурации
+func bindFlag[T any](define func(string, T, string) *T, name, usage string, field func(*Config) *T) {
+	value := define(name, *field(defaultConfig()), usage)
+	configFlags = append(configFlags, func(c *Config, set map[string]bool) {
+		if set[name] {
+			*field(c) = *value
+		}
+	})
+}
+
+// registerConfigFlags объявляет флаги, совпадающие с полями конфигурации
+func registerConfigFlags() {
+	bindFlag(flag.String, "token", "Токен Telegram бота", func(c *Config) *string { return &c.Token })
+	bindFlag(flag.Bool, "debug", "Режим отладки (true/false)", func(c *Config) *bool { return &c.Debug })
+	bindFlag(flag.Int, "concurrent", "Максимальное количество одновременных скачиваний", func(c *Config) *int { return &c.Downloads.Concurrent })
+	bindFlag(flag.Int, "ffmpeg-workers", "Максимальное количество одновременных процессов ffmpeg", func(c *Config) *int { return &c.Downloads.FFmpegWorkers })
+	bindFlag(flag.String, "data", "Каталог для хранения состояния (лимиты, квоты)", func(c *Config) *string { return &c.DataDir })
+	bindFlag(flag.Int, "user-rate", "Запросов в минуту на пользователя (0 — без ограничения)", func(c *Config) *int { return &c.Limits.UserRate })
+	bindFlag(flag.Int, "chat-rate", "Запросов в минуту на чат (0 — без ограничения)", func(c *Config) *int { return &c.Limits.ChatRate })
+	bindFlag(flag.Int, "daily-downloads", "Загрузок в сутки на пользователя (0 — без ограничения)", func(c *Config) *int { return &c.Limits.DailyDownloads })
+	bindFlag(flag.Int64, "daily-mb", "Мегабайт в сутки на пользователя (0 — без ограничения)", func(c *Config) *int64 { return &c.Limits.DailyMB })
+	bindFlag(flag.String, "webhook", "Публичный https-адрес вебхука; если не задан, используется long polling", func(c *Config) *string { return &c.Webhook.URL })
+	bindFlag(flag.String, "listen", "Адрес HTTP-сервера вебхука", func(c *Config) *string { return &c.Webhook.Listen })
+	bindFlag(flag.String, "tls-cert", "Путь к TLS-сертификату вебхука", func(c *Config) *string { return &c.Webhook.TLSCert })
+	bindFlag(flag.String, "tls-key", "Путь к закрытому ключу TLS-сертификата вебхука", func(c *Config) *string { return &c.Webhook.TLSKey })
+	bindFlag(flag.String, "metrics", "Адрес HTTP-сервера метрик Prometheus, например :9090 (пусто — отключено)", func(c *Config) *string { return &c.Metrics })
+	bindFlag(flag.String, "log-level", "Уровень логирования: debug, info, warn, error", func(c *Config) *string { return &c.Log.Level })
+	bindFlag(flag.Bool, "log-json", "Писать логи в формате JSON", func(c *Config) *bool { return &c.Log.JSON })
+}
+
+// loadConfig читает файл конфигурации и применяет переменные окружения и флаги.
+// Отсутствующий файл допустим, если путь не был задан явно (required == false).
+func loadConfig(path string, required bool) (*Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("ошибка разбора %s: %v", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+	default:
+		return nil, fmt.Errorf("не удалось прочитать файл конфигурации: %v", err)
+	}
+
+	cfg.applyEnv()
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, apply := range configFlags {
+		apply(cfg, set)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg.admins = idSet(cfg.Admins)
+	cfg.priorityUsers = idSet(cfg.PriorityUsers)
+	cfg.exemptUsers = idSet(cfg.ExemptUsers)
+	cfg.exemptChats = idSet(cfg.ExemptChats)
+	// Администраторы не ограничиваются лимитами
+	for id := range cfg.admins {
+		cfg.exemptUsers[id] = true
+	}
+	return cfg, nil
+}
+
+// applyEnv переопределяет настройки переменными окружения
+func (c *Config) applyEnv() {
+	if v := os.Getenv("TELEGRAM_BOT_TOKEN"); v != "" {
+		c.Token = v
+	}
+	if v := os.Getenv("TELEGRAM_WEBHOOK_SECRET"); v != "" {
+		c.Webhook.Secret = v
+	}
+	if v := os.Getenv("BOT_DEEPLINK_SECRET"); v != "" {
+		c.DeepLinks.Secret = v
+	}
+	for name, list := range map[string]*[]int64{
+		"BOT_ADMINS":         &c.Admins,
+		"BOT_PRIORITY_USERS": &c.PriorityUsers,
+		"BOT_EXEMPT_USERS":   &c.ExemptUsers,
+		"BOT_EXEMPT_CHATS":   &c.ExemptChats,
+	} {
+		if v := os.Getenv(name); v != "" {
+			*list = parseIDs(v)
+		}
+	}
+	// BOT_ADMIN_ID оставлен для совместимости и добавляется к списку администраторов
+	c.Admins = append(c.Admins, parseIDs(os.Getenv("BOT_ADMIN_ID"))...)
+}
+
+// validate проверяет настройки и возвращает все найденные ошибки разом
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Token != "", "не задан токен бота (token, TELEGRAM_BOT_TOKEN или -token)")
+	check(c.DataDir != "", "data_dir не может быть пустым")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(strings.ToUpper(c.Log.Level))) == nil,
+		"log.level: неизвестный уровень %q", c.Log.Level)
+
+	if c.Webhook.URL != "" {
+		u, err := neturl.Parse(c.Webhook.URL)
+		check(err == nil && u.Scheme == "https" && u.Host != "",
+			"webhook.url: Telegram принимает только https-адреса, указано %q", c.Webhook.URL)
+	}
+	check((c.Webhook.TLSCert == "") == (c.Webhook.TLSKey == ""),
+		"webhook: для TLS нужно указать и tls_cert, и tls_key")
+
+	check(c.Downloads.Concurrent >= 1, "downloads.concurrent должно быть не меньше 1")
+	check(c.Downloads.FFmpegWorkers >= 1, "downloads.ffmpeg_workers должно быть не меньше 1")
+	check(c.Downloads.Timeout > 0, "downloads.timeout должно быть положительным")
+	check(c.Downloads.MaxFileMB >= 1 && c.Downloads.MaxFileMB <= 2000,
+		"downloads.max_file_mb должно быть от 1 до 2000")
+	check(c.Downloads.UserAgent != "", "downloads.user_agent не может быть пустым")
+
+	check(c.Cleanup.Interval > 0, "cleanup.interval должно быть положительным")
+	check(c.Cleanup.UserFilesAge > 0, "cleanup.user_files_age должно быть положительным")
+	check(c.Cleanup.TempFilesAge > 0, "cleanup.temp_files_age должно быть положительным")
+
+	check(c.Limits.UserRate >= 0 && c.Limits.ChatRate >= 0 &&
+		c.Limits.DailyDownloads >= 0 && c.Limits.DailyMB >= 0,
+		"limits: значения не могут быть отрицательными")
+
+	known := map[string]bool{}
+	for _, name := range downloader.ProviderNames() {
+		known[name] = true
+	}
+	for _, name := range c.Providers.Disabled {
+		check(known[name], "providers.disabled: неизвестный провайдер %q (доступны: %s)",
+			name, strings.Join(downloader.ProviderNames(), ", "))
+	}
+
+	e := c.Providers.Endpoints
+	for name, value := range map[string]string{
+		"snapsave":          e.Snapsave,
+		"twitterdownloader": e.TwitterDownloader,
+		"snaptik":           e.Snaptik,
+		"tikmate":           e.Tikmate,
+	} {
+		u, err := neturl.Parse(value)
+		check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "",
+			"providers.endpoints.%s: некорректный адрес %q", name, value)
+	}
+	check(e.DDInstagram != "" && !strings.Contains(e.DDInstagram, "/"),
+		"providers.endpoints.ddinstagram: нужен домен без схемы и пути")
+	check(e.VXTwitter != "" && !strings.Contains(e.VXTwitter, "/"),
+		"providers.endpoints.vxtwitter: нужен домен без схемы и пути")
+
+	check(slices.Contains(captionStyles, c.Captions.Style),
+		"captions.style: неизвестный стиль %q (доступны: %s)", c.Captions.Style, strings.Join(captionStyles, ", "))
+	_, knownFormat := parseModes[c.Captions.Format]
+	check(knownFormat, "captions.format: неизвестный формат %q (доступны: html, markdownv2)", c.Captions.Format)
+	check(c.Captions.DescriptionLength >= 0 && c.Captions.DescriptionLength <= captionLimit,
+		"captions.description_length должно быть от 0 до %d", captionLimit)
+	// Telegram ждёт ответа на inline-запрос около 10 секунд
+	check(c.Inline.Wait > 0 && c.Inline.Wait <= 10*time.Second, "inline.wait должно быть от 0 до 10s")
+
+	for code, texts := range c.Messages {
+		_, ok := i18n.Lookup(code)
+		check(ok, "messages: неподдерживаемый язык %q", code)
+		for key, text := range texts {
+			check(i18n.Has(key), "messages.%s: неизвестный ключ %q", code, key)
+			check(text != "", "messages.%s.%s: текст не может быть пустым", code, key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// downloaderSettings переводит настройки в параметры пакета downloader
+func (c *Config) downloaderSettings() downloader.Settings {
+	e := c.Providers.Endpoints
+	return downloader.Settings{
+		UserAgent:            c.Downloads.UserAgent,
+		MaxFileSize:          c.Downloads.MaxFileMB * 1024 * 1024,
+		FetchMetadata:        c.Captions.Metadata,
+		FFmpegWorkers:        c.Downloads.FFmpegWorkers,
+		SnapsaveURL:          e.Snapsave,
+		TwitterDownloaderURL: e.TwitterDownloader,
+		SnaptikURL:           e.Snaptik,
+		TikmateURL:           e.Tikmate,
+		DDInstagramHost:      e.DDInstagram,
+		VXTwitterHost:        e.VXTwitter,
+	}
+}
+
+// restartRequired перечисляет изменённые настройки, которые применяются только при запуске
+func (c *Config) restartRequired(next *Config) []string {
+	var changed []string
+	for name, differs := range map[string]bool{
+		"token":                c.Token != next.Token,
+		"debug":                c.Debug != next.Debug,
+		"data_dir":             c.DataDir != next.DataDir,
+		"webhook":              c.Webhook != next.Webhook,
+		"metrics":              c.Metrics != next.Metrics,
+		"log":                  c.Log != next.Log,
+		"downloads.concurrent": c.Downloads.Concurrent != next.Downloads.Concurrent,
+		"cleanup.interval":     c.Cleanup.Interval != next.Cleanup.Interval,
+	} {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+var activeConfig atomic.Pointer[Config]
+
+// currentConfig возвращает действующую конфигурацию
+func currentConfig() *Config {
+	return activeConfig.Load()
+}
+
+// applyConfig делает конфигурацию действующей и применяет перезагружаемые
+// настройки: лимиты, провайдеров, администраторов, сообщения и параметры скачивания
+func applyConfig(cfg *Config) {
+	activeConfig.Store(cfg)
+
+	rateLimiter.configure(cfg.Limits.UserRate, cfg.Limits.ChatRate, cfg.Limits.DailyDownloads,
+		cfg.Limits.DailyMB*1024*1024, cfg.exemptUsers, cfg.exemptChats)
+	downloader.Configure(cfg.downloaderSettings())
+	applyProviders()
+
+	overrides := make(map[i18n.Lang]map[string]string, len(cfg.Messages))
+	for code, texts := range cfg.Messages {
+		lang, _ := i18n.Lookup(code)
+		overrides[lang] = texts
+	}
+	i18n.SetOverrides(overrides)
+}
+
+// applyProviders отключает провайдеров из конфигурации и отключённых администраторами
+func applyProviders() {
+	names := append(append([]string(nil), currentConfig().Providers.Disabled...), botAdmin.disabledProviders()...)
+	if err := downloader.SetDisabledProviders(names); err != nil {
+		slog.Error("Не удалось применить список отключённых провайдеров", "error", err)
+	}
+}
+
+// reloadConfig перечитывает конфигурацию по SIGHUP. При ошибке продолжает
+// работать со старой конфигурацией.
+func reloadConfig(bot *tgbotapi.BotAPI, path string, required bool) {
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		slog.Error("Конфигурация не перезагружена", "path", path, "error", err)
+		return
+	}
+
+	if changed := currentConfig().restartRequired(cfg); len(changed) > 0 {
+		slog.Warn("Изменения этих настроек вступят в силу после перезапуска", "settings", changed)
+	}
+
+	applyConfig(cfg)
+	setupBotCommands(bot)
+	slog.Info("Конфигурация перезагружена", "path", path)
+}
+
+// parseIDs разбирает список Telegram ID, разделённых запятыми
+func parseIDs(value string) []int64 {
+	var ids []int64
+	for _, field := range strings.Split(value, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func idSet(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметр /start (deep link t.me/<бот>?start=<payload>) ограничен 64 символами
+// [A-Za-z0-9_-]. Payload — base64url без выравнивания от
+//
+//	вид (1 байт) | данные | HMAC-SHA256(secret, вид|данные)[:deepLinkMACSize]
+//
+// Виды: 'u' — ссылка без https://, 'j' — ID сохранённой ссылки, если ссылка
+// не помещается в payload. Подпись не даёт подставить чужую ссылку или перебирать ID.
+const (
+	deepLinkMaxLen  = 64
+	deepLinkMACSize = 6
+
+	deepLinkURL = 'u'
+	deepLinkJob = 'j'
+)
+
+// deepLinkJobTTL — сколько хранится ссылка, переданная в payload по ID
+const deepLinkJobTTL = 7 * 24 * time.Hour
+
+// deepLinkMaxJobs — сколько ссылок хранится по ID; при переполнении
+// вытесняется ссылка, срок хранения которой истекает раньше всех
+const deepLinkMaxJobs = 10000
+
+const deepLinksStateFile = "deeplinks.json"
+
+// startInline — параметр /start кнопки-подсказки inline-режима
+const startInline = "inline"
+
+var errBadPayload = errors.New("некорректный или устаревший payload")
+
+// deepLinkStore хранит ссылки, переданные в payload по ID. Одна ссылка
+// получает один ID, пока он не устарел: inline-запрос с длинной ссылкой
+// приходит на каждое нажатие клавиши.
+type deepLinkStore struct {
+	mu    sync.Mutex
+	jobs  map[string]deepLinkJobEntry
+	ids   map[string]string // ссылка → ID
+	dirty bool
+}
+
+type deepLinkJobEntry struct {
+	Link    string    `json:"link"`
+	Expires time.Time `json:"expires"`
+}
+
+var deepLinks *deepLinkStore
+
+func newDeepLinkStore() *deepLinkStore {
+	s := &deepLinkStore{jobs: map[string]deepLinkJobEntry{}}
+	if err := loadJSON(deepLinksStateFile, &s.jobs); err != nil {
+		slog.Error("Не удалось загрузить ссылки deep link", "error", err)
+	}
+	if s.jobs == nil {
+		s.jobs = map[string]deepLinkJobEntry{}
+	}
+	s.ids = make(map[string]string, len(s.jobs))
+	for id, job := range s.jobs {
+		s.ids[job.Link] = id
+	}
+	return s
+}
+
+// add возвращает ID ссылки: прежний, если ссылка уже сохранена, иначе новый.
+// Срок хранения ссылки отсчитывается заново.
+func (s *deepLinkStore) add(link string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	id, ok := s.ids[link]
+	if job := s.jobs[id]; !ok || job.Link != link || now.After(job.Expires) {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		id = base64.RawURLEncoding.EncodeToString(b)
+
+		s.pruneLocked(now)
+		if len(s.jobs) >= deepLinkMaxJobs {
+			s.evictLocked()
+		}
+	}
+	s.jobs[id] = deepLinkJobEntry{Link: link, Expires: now.Add(deepLinkJobTTL)}
+	s.ids[link] = id
+	s.dirty = true
+	return id, nil
+}
+
+// pruneLocked удаляет устаревшие ссылки
+func (s *deepLinkStore) pruneLocked(now time.Time) {
+	for id, job := range s.jobs {
+		if now.After(job.Expires) {
+			s.deleteLocked(id)
+		}
+	}
+}
+
+// evictLocked удаляет ссылку, срок хранения которой истекает раньше всех
+func (s *deepLinkStore) evictLocked() {
+	oldest := ""
+	for id, job := range s.jobs {
+		if oldest == "" || job.Expires.Before(s.jobs[oldest].Expires) {
+			oldest = id
+		}
+	}
+	s.deleteLocked(oldest)
+}
+
+func (s *deepLinkStore) deleteLocked(id string) {
+	if s.ids[s.jobs[id].Link] == id {
+		delete(s.ids, s.jobs[id].Link)
+	}
+	delete(s.jobs, id)
+	s.dirty = true
+}
+
+// flush сохраняет ссылки, если они менялись, отбрасывая устаревшие
+func (s *deepLinkStore) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(time.Now())
+	if !s.dirty {
+		return
+	}
+	if err := saveJSON(deepLinksStateFile, s.jobs); err != nil {
+		slog.Error("Не удалось сохранить ссылки deep link", "error", err)
+		return
+	}
+	s.dirty = false
+}
+
+// startPeriodicFlush сохраняет ссылки раз в минуту
+func (s *deepLinkStore) startPeriodicFlush() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.flush()
+	}
+}
+
+// get возвращает ссылку по ID
+func (s *deepLinkStore) get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || time.Now().After(job.Expires) {
+		return "", false
+	}
+	return job.Link, true
+}
+
+// deepLinkSecret возвращает ключ подписи payload: deep_links.secret из конфигурации
+// или ключ, выведенный из токена, чтобы ссылки не устаревали после перезапуска
+func deepLinkSecret() []byte {
+	cfg := currentConfig()
+	if cfg.DeepLinks.Secret != "" {
+		return []byte(cfg.DeepLinks.Secret)
+	}
+	sum := sha256.Sum256([]byte("deeplink:" + cfg.Token))
+	return sum[:]
+}
+
+func deepLinkMAC(data []byte) []byte {
+	mac := hmac.New(sha256.New, deepLinkSecret())
+	mac.Write(data)
+	return mac.Sum(nil)[:deepLinkMACSize]
+}
+
+func signPayload(kind byte, data string) string {
+	body := append([]byte{kind}, data...)
+	return base64.RawURLEncoding.EncodeToString(append(body, deepLinkMAC(body)...))
+}
+
+// deepLinkPayload возвращает подписанный payload для /start, открывающий ссылку:
+// саму ссылку, если она помещается, иначе ID сохранённой ссылки
+func deepLinkPayload(link string) (string, error) {
+	if rest, ok := strings.CutPrefix(link, "https://"); ok {
+		if payload := signPayload(deepLinkURL, rest); len(payload) <= deepLinkMaxLen {
+			return payload, nil
+		}
+	}
+	id, err := deepLinks.add(link)
+	if err != nil {
+		return "", err
+	}
+	return signPayload(deepLinkJob, id), nil
+}
+
+// payloadLink проверяет подпись payload и возвращает ссылку, которую он открывает
+func payloadLink(payload string) (string, error) {
+	if len(payload) > deepLinkMaxLen {
+		return "", errBadPayload
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(raw) < 2+deepLinkMACSize {
+		return "", errBadPayload
+	}
+	body, mac := raw[:len(raw)-deepLinkMACSize], raw[len(raw)-deepLinkMACSize:]
+	if !hmac.Equal(mac, deepLinkMAC(body)) {
+		return "", errBadPayload
+	}
+
+	var link string
+	switch body[0] {
+	case deepLinkURL:
+		link = "https://" + string(body[1:])
+	case deepLinkJob:
+		var ok bool
+		if link, ok = deepLinks.get(string(body[1:])); !ok {
+			return "", errBadPayload
+		}
+	default:
+		return "", errBadPayload
+	}
+
+	link, ok := matchLink(link)
+	if !ok {
+		return "", errBadPayload
+	}
+	return link, nil
+}
+
+// deepLinkReply отвечает на /deeplink <ссылка>: ссылка t.me для сайтов и кнопок
+// «поделиться», которая открывает личный чат с ботом и сразу присылает видео
+func deepLinkReply(ctx context.Context, botName, args string) string {
+	lang := i18n.From(ctx)
+	link, ok := matchLink(args)
+	if !ok {
+		return lang.T("deeplink.usage")
+	}
+	payload, err := deepLinkPayload(link)
+	if err != nil {
+		logging.From(ctx).Error("Не удалось создать payload deep link", "error", err)
+		return lang.T("deeplink.failed")
+	}
+	return "https://t.me/" + botName + "?start=" + payload
+}
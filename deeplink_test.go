@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayloadLink(t *testing.T) {
+	dataDir = t.TempDir()
+	activeConfig.Store(&Config{Token: "123:test"})
+	deepLinks = newDeepLinkStore()
+	deepLinks.jobs["expired"] = deepLinkJobEntry{Link: "https://www.instagram.com/reel/Old/", Expires: time.Now().Add(-time.Minute)}
+
+	short := "https://www.instagram.com/reel/C1a2b3/"
+	long := "https://www.tiktok.com/@" + strings.Repeat("user", 10) + "/video/7300000000000000000"
+	shortPayload, err := deepLinkPayload(short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	longPayload, err := deepLinkPayload(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Последний символ base64 несёт и младшие биты подписи, поэтому меняется первый
+	tampered := "A" + shortPayload[1:]
+	if tampered == shortPayload {
+		tampered = "B" + shortPayload[1:]
+	}
+
+	activeConfig.Store(&Config{Token: "123:test", DeepLinks: deepLinksConfig{Secret: "other"}})
+	foreign := signPayload(deepLinkURL, strings.TrimPrefix(short, "https://"))
+	activeConfig.Store(&Config{Token: "123:test"})
+
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{name: "link in payload", payload: shortPayload, want: short},
+		{name: "link by id", payload: longPayload, want: long},
+		{name: "tampered", payload: tampered},
+		{name: "signed with other secret", payload: foreign},
+		{name: "truncated mac", payload: shortPayload[:len(shortPayload)-2]},
+		{name: "expired id", payload: signPayload(deepLinkJob, "expired")},
+		{name: "unknown id", payload: signPayload(deepLinkJob, "missing")},
+		{name: "unknown kind", payload: signPayload('x', "www.instagram.com/reel/C1a2b3/")},
+		{name: "unsupported link", payload: signPayload(deepLinkURL, "example.com/video")},
+		{name: "over 64 characters", payload: signPayload(deepLinkURL, "www.instagram.com/reel/"+strings.Repeat("a", 40)+"/")},
+		{name: "not base64", payload: "inline!"},
+		{name: "too short", payload: "inline"},
+		{name: "empty", payload: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := payloadLink(tt.payload)
+			if tt.want == "" {
+				if !errors.Is(err, errBadPayload) {
+					t.Errorf("payloadLink(%q) = %q, %v, want errBadPayload", tt.payload, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("payloadLink(%q) = %q, %v, want %q", tt.payload, got, err, tt.want)
+			}
+		})
+	}
+
+	if len(shortPayload) > deepLinkMaxLen || len(longPayload) > deepLinkMaxLen {
+		t.Errorf("payloads longer than %d characters: %q, %q", deepLinkMaxLen, shortPayload, longPayload)
+	}
+}
+
+func TestDeepLinkStoreAdd(t *testing.T) {
+	dataDir = t.TempDir()
+	s := newDeepLinkStore()
+
+	link := "https://www.tiktok.com/@user/video/7300000000000000000"
+	first, err := s.add(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.add(link)
+	if err != nil || again != first {
+		t.Errorf("add again = %q, %v, want the same ID %q", again, err, first)
+	}
+
+	// Устаревший ID не переиспользуется
+	s.jobs[first] = deepLinkJobEntry{Link: link, Expires: time.Now().Add(-time.Minute)}
+	renewed, err := s.add(link)
+	if err != nil || renewed == first {
+		t.Errorf("add after expiry = %q, %v, want a new ID", renewed, err)
+	}
+	if _, ok := s.jobs[first]; ok {
+		t.Errorf("expired ID %q is still stored", first)
+	}
+
+	for i := len(s.jobs); i < deepLinkMaxJobs+10; i++ {
+		if _, err := s.add(fmt.Sprintf("https://www.tiktok.com/@user/video/%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.jobs) != deepLinkMaxJobs || len(s.ids) != deepLinkMaxJobs {
+		t.Errorf("store holds %d jobs and %d ids, want %d", len(s.jobs), len(s.ids), deepLinkMaxJobs)
+	}
+	if _, ok := s.get(renewed); ok {
+		t.Errorf("the oldest link was not evicted")
+	}
+
+	// Ссылки сохраняются только при flush и загружаются вместе с индексом
+	s.flush()
+	loaded := newDeepLinkStore()
+	last := fmt.Sprintf("https://www.tiktok.com/@user/video/%d", deepLinkMaxJobs+9)
+	if id, err := loaded.add(last); err != nil || id != s.ids[last] {
+		t.Errorf("add after reload = %q, %v, want %q", id, err, s.ids[last])
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// delivery описывает, как отправлять результат в чат: с учётом настроек
+// группы и сообщения, в котором пришла ссылка
+type delivery struct {
+	chatID     int64
+	topicID    int    // тема форума, в которой пришло сообщение; 0 — вне темы
+	messageID  int    // сообщение со ссылкой
+	deleteID   int    // сообщение, удаляемое при включённом DeleteOriginal
+	forceReply bool   // отвечать на messageID независимо от настроек группы
+	requester  string // имя пользователя, запросившего скачивание; только в группах
+	lang       i18n.Lang
+	settings   chatSettings
+	convert    conversion // преобразование по команде вроде /gif
+}
+
+// conversion — преобразование скачанного видео перед отправкой; нулевое значение — без преобразования
+type conversion struct {
+	name   string              // суффикс ключа кэша: результаты разных преобразований кэшируются отдельно
+	stages downloader.Pipeline // шаги преобразования после общей обработки
+	send   fileKind            // способ отправки результата; fileVideo — по содержимому, как без преобразования
+}
+
+// fileKind — способ отправки файла; по нему же файл отправляется повторно по file_id
+type fileKind int
+
+const (
+	fileVideo fileKind = iota
+	fileAnimation
+	fileAudio
+	fileDocument
+	fileVideoNote
+)
+
+// sendMethods — метод Bot API и имя поля с файлом для каждого способа отправки
+var sendMethods = map[fileKind][2]string{
+	fileVideo:     {"sendVideo", "video"},
+	fileAnimation: {"sendAnimation", "animation"},
+	fileAudio:     {"sendAudio", "audio"},
+	fileDocument:  {"sendDocument", "document"},
+	fileVideoNote: {"sendVideoNote", "video_note"},
+}
+
+func newDelivery(ctx context.Context, message *tgbotapi.Message, topicID int) delivery {
+	settings := groupSettings.get(message.Chat.ID)
+	if message.Chat.IsPrivate() {
+		// В личном чате понятно, к какой ссылке относится ответ
+		settings = defaultChatSettings()
+		settings.Reply = false
+	}
+	d := delivery{
+		chatID:    message.Chat.ID,
+		topicID:   topicID,
+		messageID: message.MessageID,
+		deleteID:  message.MessageID,
+		lang:      i18n.From(ctx),
+		settings:  settings,
+	}
+	if !message.Chat.IsPrivate() {
+		d.requester = requesterName(message.From)
+	}
+	return d
+}
+
+// replyingTo направляет результат ответом на сообщение source, в котором найдены
+// ссылки. При включённом удалении удаляется сообщение с командой, а не source:
+// иначе результат остался бы ответом на удалённое сообщение.
+func (d delivery) replyingTo(source *tgbotapi.Message) delivery {
+	d.messageID = source.MessageID
+	d.forceReply = true
+	return d
+}
+
+// requesterName возвращает имя пользователя для подписи
+func requesterName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// replyTo возвращает ID сообщения, на которое отвечают результат и служебные
+// сообщения; 0 — обычная отправка
+func (d delivery) replyTo() int {
+	if d.settings.Reply || d.forceReply {
+		return d.messageID
+	}
+	return 0
+}
+
+// caption возвращает подпись к видео в стиле, выбранном группой или заданном в конфигурации
+func (d delivery) caption(link string, meta downloader.Metadata) string {
+	cfg := currentConfig().Captions
+	return buildCaption(d.lang, d.settings.captionStyle(), cfg.Format, cfg.DescriptionLength,
+		link, linkPlatform(link), meta, d.requester)
+}
+
+// captionParams возвращает параметры caption и parse_mode для отправки файла
+func (d delivery) captionParams(link string, meta downloader.Metadata) tgbotapi.Params {
+	params := tgbotapi.Params{}
+	if link == "" {
+		// Видео из Telegram: источника, на который ссылается подпись, нет
+		return params
+	}
+	if caption := d.caption(link, meta); caption != "" {
+		params["caption"] = caption
+		params["parse_mode"] = parseModes[currentConfig().Captions.Format]
+	}
+	return params
+}
+
+// commandConversion возвращает преобразование для команды /gif, /clip или /note с аргументами args
+func commandConversion(command, args string) (conversion, error) {
+	switch command {
+	case "gif":
+		return gifConversion(args), nil
+	case "clip":
+		start, end, err := parseClipRange(args)
+		if err != nil {
+			return conversion{}, err
+		}
+		return clipConversion(start, end), nil
+	case "note":
+		return noteConversion, nil
+	}
+	return conversion{}, fmt.Errorf("команда /%s не преобразует видео", command)
+}
+
+// converting возвращает доставку, которая преобразует видео перед отправкой.
+// Режим «только аудио» группы к результату преобразования не применяется.
+func (d delivery) converting(convert conversion) delivery {
+	d.convert = convert
+	d.settings.AudioOnly = false
+	return d
+}
+
+// cacheKey — ключ кэша file_id: аудио, видео и результаты преобразований
+// по одной ссылке кэшируются отдельно
+func (d delivery) cacheKey(link string) string {
+	switch {
+	case d.convert.name != "":
+		return link + "#" + d.convert.name
+	case d.settings.AudioOnly:
+		return link + "#audio"
+	}
+	return link
+}
+
+// fileKind возвращает способ отправки скачанного файла
+func (d delivery) fileKind(media *downloader.Media) fileKind {
+	switch {
+	case d.settings.AudioOnly:
+		return fileAudio
+	case d.convert.send != fileVideo:
+		return d.convert.send
+	case media.Animation:
+		return fileAnimation
+	}
+	return fileVideo
+}
+
+// useCache сообщает, можно ли отправить результат из кэша. При ограничении
+// длины видео нужно скачать заново: длительность кэшированного файла неизвестна.
+func (d delivery) useCache() bool {
+	return d.settings.MaxDuration == 0
+}
+
+// pipeline собирает обработку скачанного видео перед отправкой: общую обработку,
+// проверку длины по настройкам группы, извлечение звука и преобразование по команде
+func (d delivery) pipeline() downloader.Pipeline {
+	stages := append(downloader.Pipeline{}, downloader.PostProcessing...)
+	if limit := d.settings.MaxDuration; limit > 0 {
+		stages = append(stages, maxDurationStage(time.Duration(limit)*time.Second))
+	}
+	if d.settings.AudioOnly {
+		stages = append(stages, downloader.ExtractAudio(), downloader.Tag())
+	}
+	return append(stages, d.convert.stages...)
+}
+
+// prepare обрабатывает скачанное видео перед отправкой; при ошибке файл удаляется.
+// Слот скачивания к этому моменту должен быть освобождён.
+func (d delivery) prepare(ctx context.Context, media *downloader.Media) error {
+	if err := d.pipeline().Run(ctx, media); err != nil {
+		media.Remove()
+		return err
+	}
+	return nil
+}
+
+// maxDurationStage — шаг, который отклоняет видео длиннее limit
+func maxDurationStage(limit time.Duration) downloader.Stage {
+	return downloader.Stage{Name: "max_duration", Run: func(ctx context.Context, job *downloader.Job) error {
+		duration := downloader.ProbeVideo(job.Media.Path).Duration
+		if duration > limit {
+			return fmt.Errorf("%w: %s", errTooLong, duration.Round(time.Second))
+		}
+		return nil
+	}}
+}
+
+// deleteOriginal удаляет сообщение со ссылкой, если это включено в настройках группы.
+// Для удаления у бота должно быть право удалять сообщения.
+func (d delivery) deleteOriginal(ctx context.Context, bot *tgbotapi.BotAPI) {
+	if !d.settings.DeleteOriginal {
+		return
+	}
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(d.chatID, d.deleteID)); err != nil {
+		logging.From(ctx).Warn("Не удалось удалить сообщение со ссылкой", "message_id", d.deleteID, "error", err)
+	}
+}
+
+// params возвращает общие параметры отправки: чат, тему форума и сообщение, на которое
+// отвечает бот. Конфигурации tgbotapi не поддерживают message_thread_id, поэтому
+// все сообщения, связанные со ссылкой, отправляются запросами с параметрами, собранными вручную.
+func (d delivery) params() tgbotapi.Params {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", d.chatID)
+	params.AddNonZero("message_thread_id", d.topicID)
+	if replyTo := d.replyTo(); replyTo != 0 {
+		params.AddNonZero("reply_to_message_id", replyTo)
+		// Сообщение со ссылкой могли удалить, пока шло скачивание
+		params.AddBool("allow_sending_without_reply", true)
+	}
+	return params
+}
+
+// sendText отправляет служебное сообщение: прогресс, ошибку или сводку
+func (d delivery) sendText(bot *tgbotapi.BotAPI, text string) (tgbotapi.Message, error) {
+	params := d.params()
+	params["text"] = text
+
+	var msg tgbotapi.Message
+	err := sendRequest(bot, "sendMessage", params, nil, &msg)
+	return msg, err
+}
+
+// sendFile отправляет файл методом method (sendVideo, sendAudio, sendAnimation): загружает его
+// или передаёт file_id. Дополнительные параметры, например подпись, берутся из extra,
+// вложения attach:// вроде обложки — из attachments.
+func (d delivery) sendFile(bot *tgbotapi.BotAPI, method, field string, file tgbotapi.RequestFileData, extra tgbotapi.Params, attachments ...tgbotapi.RequestFile) (tgbotapi.Message, error) {
+	params := d.params()
+	for key, value := range extra {
+		params[key] = value
+	}
+
+	files := attachments
+	if file.NeedsUpload() {
+		files = append(files, tgbotapi.RequestFile{Name: field, Data: file})
+	} else {
+		params[field] = file.SendData()
+	}
+
+	var msg tgbotapi.Message
+	err := sendRequest(bot, method, params, files, &msg)
+	return msg, err
+}
+
+// thumbnailAttachName — имя части multipart-запроса с обложкой
+const thumbnailAttachName = "thumbnail_file"
+
+// attachThumbnail добавляет в params ссылку на обложку и возвращает вложение с файлом.
+// Обложку нельзя передать по file_id, только загрузить как attach://; пустой путь — без обложки.
+func attachThumbnail(params tgbotapi.Params, thumbnail string) []tgbotapi.RequestFile {
+	if thumbnail == "" {
+		return nil
+	}
+	params["thumbnail"] = "attach://" + thumbnailAttachName
+	return []tgbotapi.RequestFile{{Name: thumbnailAttachName, Data: tgbotapi.FilePath(thumbnail)}}
+}
+
+// sendRequest выполняет запрос к Bot API, загружая файлы, если они есть, и разбирает результат
+func sendRequest(bot *tgbotapi.BotAPI, method string, params tgbotapi.Params, files []tgbotapi.RequestFile, result any) error {
+	var resp *tgbotapi.APIResponse
+	var err error
+	if len(files) > 0 {
+		resp, err = bot.UploadFiles(method, params, files)
+	} else {
+		resp, err = bot.MakeRequest(method, params)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.Result, result)
+}
@@ -151,6 +151,7 @@ User=$SERVICE_USER
 WorkingDirectory=$INSTALL_DIR
 EnvironmentFile=$TOKEN_FILE
 ExecStart=$INSTALL_DIR/$APP_NAME -concurrent=$CONCURRENT_DOWNLOADS -debug=$DEBUG_MODE
+ExecReload=/bin/kill -HUP \$MAINPID
 Restart=always
 RestartSec=10
 StandardOutput=syslog
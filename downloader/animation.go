@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"os"
+	"time"
+)
+
+// Ограничения /gif: анимация в Telegram — короткий ролик без звука, который
+// проигрывается по кругу. Более длинные ролики обрезаются.
+const (
+	AnimationMaxDuration = 30 * time.Second
+	GIFMaxDuration       = 15 * time.Second
+
+	animationMaxBytes = 10 * 1024 * 1024
+	gifMaxBytes       = 15 * 1024 * 1024
+
+	// twitterGIFMaxDuration — GIF в Twitter короткие; длинное видео без звука — обычное видео
+	twitterGIFMaxDuration = time.Minute
+)
+
+// conversionTimeout — сколько ждать шага перекодирования
+const conversionTimeout = 2 * time.Minute
+
+// animationScale уменьшает видео до 720 пикселей по ширине; высота остаётся чётной для H.264
+const animationScale = "scale='min(720,iw)':-2"
+
+// gifAttempt — параметры кодирования GIF; следующие варианты меньше по размеру
+type gifAttempt struct {
+	fps, width int
+}
+
+var gifAttempts = []gifAttempt{{15, 480}, {10, 360}, {8, 240}}
+
+// DetectAnimation — шаг, который отмечает зацикленные анимации для отправки как GIF
+func DetectAnimation() Stage {
+	return Stage{Name: "detect_animation", Run: func(ctx context.Context, job *Job) error {
+		job.Media.Animation = isAnimation(job.Media.Platform, job.Media.Path)
+		return nil
+	}}
+}
+
+// isAnimation определяет, что скачанный файл — зацикленная анимация: настоящий GIF
+// или GIF из Twitter, который сервисы отдают как MP4 без звуковой дорожки.
+// На других платформах видео без звука остаётся обычным видео.
+func isAnimation(platform PlatformType, path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	head := make([]byte, 16)
+	n, _ := f.Read(head)
+	f.Close()
+
+	switch sniffMedia(head[:n]) {
+	case kindGIF:
+		return true
+	case kindMP4:
+		if platform != Twitter {
+			return false
+		}
+		info, err := ProbeMP4(path)
+		return err == nil && info.VideoCodec != "" && info.AudioCodec == "" &&
+			info.Duration > 0 && info.Duration <= twitterGIFMaxDuration
+	}
+	return false
+}
+
+// Animation — шаг, который перекодирует видео в MP4 без звука не длиннее
+// AnimationMaxDuration и не больше 10 МБ, который Telegram показывает как GIF
+func Animation() Stage {
+	return Stage{Name: "animation", Timeout: conversionTimeout, Run: convertToAnimation}
+}
+
+func convertToAnimation(ctx context.Context, job *Job) error {
+	logger := logging.From(ctx)
+	start := time.Now()
+	out := job.Output(".anim.mp4")
+	args := []string{"-i", job.Media.Path, "-t", timestamp(AnimationMaxDuration), "-an", "-vf", animationScale,
+		"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p", "-movflags", "+faststart"}
+
+	if err := encode(ctx, append(args, "-crf", "28", out)...); err != nil {
+		return err
+	}
+
+	// Не поместилось с постоянным качеством — повтор с битрейтом, рассчитанным на лимит
+	if size := fileSize(out); size > animationMaxBytes {
+		info, err := ProbeMP4(out)
+		if err != nil || info.Duration <= 0 {
+			return fmt.Errorf("%w: анимация %d байт", ErrTooLarge, size)
+		}
+		bitrate := int64(float64(animationMaxBytes*8) * 0.9 / info.Duration.Seconds())
+		rate := fmt.Sprint(bitrate)
+		if err := encode(ctx, append(args, "-b:v", rate, "-maxrate", rate, "-bufsize", rate, out)...); err != nil {
+			return err
+		}
+		if size := fileSize(out); size > animationMaxBytes {
+			return fmt.Errorf("%w: анимация %d байт", ErrTooLarge, size)
+		}
+	}
+
+	job.Replace(out)
+	job.Media.Animation = true
+	logger.Info("Видео преобразовано в анимацию", "bytes", fileSize(out), "elapsed", time.Since(start))
+	return nil
+}
+
+// GIF — шаг, который перекодирует видео в GIF не длиннее GIFMaxDuration с палитрой,
+// подобранной по кадрам, уменьшая частоту и размер кадров, пока файл не станет меньше 15 МБ
+func GIF() Stage {
+	return Stage{Name: "gif", Timeout: conversionTimeout, Run: convertToGIF}
+}
+
+func convertToGIF(ctx context.Context, job *Job) error {
+	logger := logging.From(ctx)
+	start := time.Now()
+	out := job.Output(".anim.gif")
+
+	var size int64
+	for _, attempt := range gifAttempts {
+		filter := fmt.Sprintf("fps=%d,scale='min(%d,iw)':-1:flags=lanczos,split[a][b];[a]palettegen=max_colors=128[p];[b][p]paletteuse=dither=bayer",
+			attempt.fps, attempt.width)
+		if err := encode(ctx, "-i", job.Media.Path, "-t", timestamp(GIFMaxDuration), "-an", "-filter_complex", filter, "-loop", "0", out); err != nil {
+			return err
+		}
+		if size = fileSize(out); size <= gifMaxBytes {
+			job.Replace(out)
+			job.Media.Animation = true
+			logger.Info("Видео преобразовано в GIF", "bytes", size, "fps", attempt.fps, "width", attempt.width,
+				"elapsed", time.Since(start))
+			return nil
+		}
+		logger.Debug("GIF больше лимита, уменьшаю", "bytes", size, "fps", attempt.fps, "width", attempt.width)
+	}
+	return fmt.Errorf("%w: GIF %d байт", ErrTooLarge, size)
+}
+
+// encode запускает ffmpeg для перекодирования; ошибку ffmpeg и истечение
+// таймаута шага оборачивает в ErrConversion
+func encode(ctx context.Context, args ...string) error {
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return fmt.Errorf("%w: %v", ErrConversion, err)
+	}
+	return nil
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
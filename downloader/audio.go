@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tagTitleLength — сколько символов описания поста попадает в название трека
+const tagTitleLength = 100
+
+// ExtractAudio — шаг, который извлекает звуковую дорожку видео в файл .m4a
+func ExtractAudio() Stage {
+	return Stage{Name: "extract_audio", Timeout: conversionTimeout, Run: extractAudio}
+}
+
+func extractAudio(ctx context.Context, job *Job) error {
+	start := time.Now()
+	out := job.Output(".m4a")
+	if err := runFFmpeg(ctx, "-i", job.Media.Path, "-vn", "-c:a", "aac", "-b:a", "192k", out); err != nil {
+		return fmt.Errorf("%w: не удалось извлечь звук: %v", ErrConversion, err)
+	}
+	job.Replace(out)
+	logging.From(ctx).Info("Звук извлечён из видео", "bytes", fileSize(out), "elapsed", time.Since(start))
+	return nil
+}
+
+// Tag — шаг, который записывает в файл автора и описание поста как исполнителя
+// и название без перекодирования: их показывают плееры. Шаг необязательный.
+func Tag() Stage {
+	return Stage{Name: "tag", Timeout: remuxTimeout, Optional: true, Run: tagMetadata}
+}
+
+func tagMetadata(ctx context.Context, job *Job) error {
+	meta := job.Media.Meta
+	artist := meta.Author
+	if artist == "" {
+		artist = meta.Handle
+	}
+	if artist == "" && meta.Description == "" {
+		return nil
+	}
+
+	out := job.Output(".tagged" + filepath.Ext(job.Media.Path))
+	args := []string{"-i", job.Media.Path, "-map", "0", "-c", "copy"}
+	if artist != "" {
+		args = append(args, "-metadata", "artist="+artist)
+	}
+	if meta.Description != "" {
+		args = append(args, "-metadata", "title="+tagTitle(meta.Description))
+	}
+	if err := runFFmpeg(ctx, append(args, out)...); err != nil {
+		return fmt.Errorf("запись метаданных: %w", err)
+	}
+	job.Replace(out)
+	return nil
+}
+
+// tagTitle возвращает первую строку описания поста не длиннее tagTitleLength символов
+func tagTitle(description string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(description), "\n")
+	if runes := []rune(title); len(runes) > tagTitleLength {
+		title = strings.TrimSpace(string(runes[:tagTitleLength])) + "…"
+	}
+	return title
+}
@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"time"
+)
+
+// keyframeTolerance — насколько начало фрагмента может отстоять от ключевого кадра,
+// чтобы вырезать его без перекодирования
+const keyframeTolerance = 100 * time.Millisecond
+
+// Trim — шаг, который вырезает из видео фрагмент от start до end; конец за пределами
+// видео означает фрагмент до конца. Если фрагмент начинается на ключевом кадре,
+// потоки копируются без перекодирования, иначе видео перекодируется,
+// чтобы фрагмент начинался точно с нужного момента.
+func Trim(start, end time.Duration) Stage {
+	return Stage{Name: "trim", Timeout: conversionTimeout, Run: func(ctx context.Context, job *Job) error {
+		return trimVideo(ctx, job, start, end)
+	}}
+}
+
+func trimVideo(ctx context.Context, job *Job, start, end time.Duration) error {
+	logger := logging.From(ctx)
+	begin := time.Now()
+	out := job.Output(".clip.mp4")
+	args := []string{"-ss", timestamp(start), "-i", job.Media.Path, "-t", timestamp(end - start), "-map", "0:v:0", "-map", "0:a:0?"}
+
+	copyStreams := startsOnKeyframe(job.Media.Path, start)
+	if copyStreams {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	} else {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p", "-c:a", "aac", "-b:a", "128k")
+	}
+	if err := encode(ctx, append(args, "-movflags", "+faststart", out)...); err != nil {
+		return err
+	}
+
+	job.Replace(out)
+	logger.Info("Фрагмент видео вырезан", "start", start, "end", end, "stream_copy", copyStreams,
+		"bytes", fileSize(out), "elapsed", time.Since(begin))
+	return nil
+}
+
+// startsOnKeyframe проверяет, есть ли ключевой кадр в момент start. Для форматов,
+// кроме MP4, ключевые кадры неизвестны, и видео перекодируется.
+func startsOnKeyframe(path string, start time.Duration) bool {
+	keyframe, ok, err := MP4KeyframeBefore(path, start+keyframeTolerance)
+	if err != nil {
+		return false
+	}
+	return start == 0 || (ok && start-keyframe <= keyframeTolerance)
+}
+
+// timestamp форматирует длительность для аргументов ffmpeg в секундах с миллисекундами
+func timestamp(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"goland/VideoSaverBot/logging"
 	"io"
 	"math"
 	"net/http"
@@ -35,17 +37,19 @@ func min(a, b int) int {
 type SnapsaveResponse struct {
 	Success bool `json:"success"`
 	Data    struct {
-		Description string `json:"description,omitempty"`
-		Preview     string `json:"preview,omitempty"`
-		Media       []struct {
-			URL        string `json:"url"`
-			Thumbnail  string `json:"thumbnail,omitempty"`
-			Type       string `json:"type"`
-			Resolution string `json:"resolution,omitempty"`
-		} `json:"media"`
+		Description string          `json:"description,omitempty"`
+		Preview     string          `json:"preview,omitempty"`
+		Media       []SnapsaveMedia `json:"media"`
 	} `json:"data"`
 }
 
+type SnapsaveMedia struct {
+	URL        string `json:"url"`
+	Thumbnail  string `json:"thumbnail,omitempty"`
+	Type       string `json:"type"`
+	Resolution string `json:"resolution,omitempty"`
+}
+
 type PlatformType string
 
 const (
@@ -53,8 +57,127 @@ const (
 	Twitter   PlatformType = "twitter"
 	TikTok    PlatformType = "tiktok"
 	Facebook  PlatformType = "facebook"
+	YouTube   PlatformType = "youtube"
 )
 
+// Media — результат успешного скачивания
+type Media struct {
+	Path      string
+	Thumbnail string // обложка JPEG не больше 320×320; пусто — без обложки
+	Platform  PlatformType
+	Provider  string   // имя провайдера, который вернул видео
+	Meta      Metadata // автор и описание поста, если Settings.FetchMetadata
+	Animation bool     // зацикленная анимация без звука, отправляется как GIF
+}
+
+// Remove удаляет скачанный файл и его обложку
+func (m *Media) Remove() error {
+	if m.Thumbnail != "" {
+		os.Remove(m.Thumbnail)
+	}
+	return os.Remove(m.Path)
+}
+
+// Observer получает события скачивания, например для метрик
+type Observer interface {
+	// ProviderResult вызывается после каждой попытки провайдера
+	ProviderResult(platform PlatformType, provider string, err error, elapsed time.Duration)
+	// ToolFailure вызывается при ошибке запуска внешней утилиты (yt-dlp, ffmpeg)
+	ToolFailure(tool string)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ProviderResult(PlatformType, string, error, time.Duration) {}
+func (nopObserver) ToolFailure(string)                                        {}
+
+var observer Observer = nopObserver{}
+
+// SetObserver устанавливает получателя событий скачивания
+func SetObserver(o Observer) {
+	observer = o
+}
+
+// provider — один способ получить видео; провайдеры платформы
+// пробуются по порядку, пока один из них не вернёт файл.
+// Провайдер заполняет Path и, если есть, Thumbnail — превью, скачанное с сервиса.
+type provider struct {
+	name     string
+	download func(ctx context.Context, mediaURL string, userID int64) (*Media, error)
+}
+
+var providers = map[PlatformType][]provider{
+	Instagram: {{"snapsave", snapsaveDownload}, {"ddinstagram", videoOnly(fallbackInstagramDownload)}},
+	Twitter:   {{"snapsave", snapsaveDownload}, {"vxtwitter", videoOnly(fallbackTwitterDownload)}},
+	TikTok:    {{"snaptik", snapsaveDownload}, {"tikmate", videoOnly(fallbackTikTokDownload)}},
+	Facebook:  {{"snapsave", snapsaveDownload}},
+	YouTube:   {{"yt-dlp", videoOnly(ytDlpDownload)}},
+}
+
+// videoOnly адаптирует провайдера, который возвращает только путь к видео
+func videoOnly(download func(context.Context, string, int64) (string, error)) func(context.Context, string, int64) (*Media, error) {
+	return func(ctx context.Context, mediaURL string, userID int64) (*Media, error) {
+		path, err := download(ctx, mediaURL, userID)
+		if err != nil {
+			return nil, err
+		}
+		return &Media{Path: path}, nil
+	}
+}
+
+// download проходит по цепочке провайдеров платформы и возвращает первый успешный результат.
+// Отключённые провайдеры пропускаются.
+func download(ctx context.Context, platform PlatformType, mediaURL string, userID int64) (*Media, error) {
+	chain, ok := providers[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, platform)
+	}
+
+	// Сведения о посте запрашиваются параллельно со скачиванием
+	var meta chan Metadata
+	if settings().FetchMetadata {
+		meta = make(chan Metadata, 1)
+		go func() { meta <- fetchMetadata(ctx, platform, mediaURL) }()
+	}
+
+	var lastErr error
+	for _, p := range chain {
+		if !ProviderEnabled(p.name) {
+			continue
+		}
+
+		pctx := logging.With(ctx, "platform", platform, "provider", p.name, "stage", "download")
+		logger := logging.From(pctx)
+
+		start := time.Now()
+		media, err := p.download(pctx, mediaURL, userID)
+		elapsed := time.Since(start)
+		observer.ProviderResult(platform, p.name, err, elapsed)
+		if err == nil {
+			logger.Info("Видео скачано", "path", media.Path, "elapsed", elapsed)
+			media.Platform, media.Provider = platform, p.name
+			if meta != nil {
+				media.Meta = <-meta
+			}
+			return media, nil
+		}
+
+		logger.Warn("Провайдер не смог скачать видео", "error", err, "elapsed", elapsed)
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("%w для платформы %s", ErrProvidersDisabled, platform)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %v", ErrTimeout, lastErr)
+	}
+	return nil, classify(lastErr)
+}
+
 // decodeSnapApp расшифровывает данные согласно алгоритму snapsave
 func decodeSnapApp(args []string) string {
 	if len(args) < 6 {
@@ -281,7 +404,7 @@ func fixThumbnail(url string) string {
 }
 
 func getUserAgent() string {
-	return "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"
+	return settings().UserAgent
 }
 
 func generateUniqueID() string {
@@ -309,27 +432,64 @@ func detectPlatform(mediaURL string) PlatformType {
 	}
 }
 
-func snapsaveDownload(ctx context.Context, mediaURL string, userID int64) (string, error) {
+// snapsaveDownload скачивает видео через snapsave.app и его сервисы (snaptik, twitterdownloader)
+// вместе с превью, которое показывает сервис
+func snapsaveDownload(ctx context.Context, mediaURL string, userID int64) (*Media, error) {
 	platform := detectPlatform(mediaURL)
 
-	outputPath, err := createUserDirectory(userID, string(platform))
+	outputPath, err := createUserDirectory(ctx, userID, string(platform))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	videoURL, err := getSnapsaveVideoURL(ctx, mediaURL)
+	result, err := getSnapsaveVideoURL(ctx, mediaURL)
 	if err != nil {
-		return fallbackDownload(ctx, mediaURL, userID, platform)
+		return nil, err
 	}
 
-	result, err := downloadMedia(ctx, videoURL, outputPath)
+	video := result.Data.Media[0]
+	path, err := downloadMedia(ctx, video.URL, outputPath)
 	if err != nil {
-		return fallbackDownload(ctx, mediaURL, userID, platform)
+		return nil, err
 	}
-	return result, nil
+
+	thumbnail := video.Thumbnail
+	if thumbnail == "" {
+		thumbnail = result.Data.Preview
+	}
+	return &Media{Path: path, Thumbnail: downloadThumbnail(ctx, thumbnail, path)}, nil
 }
 
-func getSnapsaveVideoURL(ctx context.Context, mediaURL string) (string, error) {
+// snapsaveResult собирает ответ snapsave из расшифрованной страницы: ссылку на видео
+// и превью. doc равен nil, если ссылка найдена регулярным выражением без разбора страницы.
+func snapsaveResult(doc *goquery.Document, videoURL string) *SnapsaveResponse {
+	result := &SnapsaveResponse{Success: true}
+	result.Data.Media = []SnapsaveMedia{{URL: videoURL, Type: "video"}}
+	if doc == nil {
+		return result
+	}
+
+	// Превью элемента списка загрузок точнее первой картинки страницы
+	result.Data.Media[0].Thumbnail = imageSrc(doc.Find(".download-items__thumb img"))
+	result.Data.Preview = imageSrc(doc.Find("img"))
+	return result
+}
+
+// imageSrc возвращает адрес первой картинки выборки с абсолютной ссылкой
+func imageSrc(images *goquery.Selection) string {
+	var src string
+	images.EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		candidate := s.AttrOr("src", s.AttrOr("data-src", ""))
+		if strings.HasPrefix(candidate, "http") {
+			src = fixThumbnail(candidate)
+			return false
+		}
+		return true
+	})
+	return src
+}
+
+func getSnapsaveVideoURL(ctx context.Context, mediaURL string) (*SnapsaveResponse, error) {
 	platform := detectPlatform(mediaURL)
 
 	switch platform {
@@ -340,80 +500,80 @@ func getSnapsaveVideoURL(ctx context.Context, mediaURL string) (string, error) {
 	case Instagram, Facebook:
 		return getSnapsaveVideoURLInstagramFacebook(ctx, mediaURL)
 	default:
-		return "", fmt.Errorf("неподдерживаемая платформа: %s", platform)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, platform)
 	}
 }
 
-func getSnapsaveVideoURLTikTok(ctx context.Context, mediaURL string) (string, error) {
+func getSnapsaveVideoURLTikTok(ctx context.Context, mediaURL string) (*SnapsaveResponse, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
-	homeReq, err := http.NewRequestWithContext(ctx, "GET", "https://snaptik.app/", nil)
+	homeReq, err := http.NewRequestWithContext(ctx, "GET", settings().SnaptikURL+"/", nil)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса к snaptik.app: %v", err)
+		return nil, fmt.Errorf("ошибка создания запроса к snaptik.app: %v", err)
 	}
 
 	homeReq.Header.Set("User-Agent", getUserAgent())
 
 	homeResp, err := client.Do(homeReq)
 	if err != nil {
-		return "", fmt.Errorf("ошибка запроса к snaptik.app: %v", err)
+		return nil, fmt.Errorf("ошибка запроса к snaptik.app: %v", err)
 	}
 	defer homeResp.Body.Close()
 
 	if homeResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неверный статус код от snaptik.app: %d", homeResp.StatusCode)
+		return nil, fmt.Errorf("неверный статус код от snaptik.app: %d", homeResp.StatusCode)
 	}
 
 	homeDoc, err := goquery.NewDocumentFromReader(homeResp.Body)
 	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга HTML snaptik.app: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга HTML snaptik.app: %v", err)
 	}
 
 	token, exists := homeDoc.Find("input[name='token']").Attr("value")
 	if !exists || token == "" {
-		return "", fmt.Errorf("токен не найден на странице snaptik.app")
+		return nil, fmt.Errorf("токен не найден на странице snaptik.app")
 	}
 
 	formData := neturl.Values{}
 	formData.Set("url", mediaURL)
 	formData.Set("token", token)
 
-	postReq, err := http.NewRequestWithContext(ctx, "POST", "https://snaptik.app/abc2.php", strings.NewReader(formData.Encode()))
+	postReq, err := http.NewRequestWithContext(ctx, "POST", settings().SnaptikURL+"/abc2.php", strings.NewReader(formData.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания POST-запроса к snaptik.app: %v", err)
+		return nil, fmt.Errorf("ошибка создания POST-запроса к snaptik.app: %v", err)
 	}
 
 	postReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	postReq.Header.Set("Accept", "*/*")
-	postReq.Header.Set("Origin", "https://snaptik.app")
-	postReq.Header.Set("Referer", "https://snaptik.app/")
+	postReq.Header.Set("Origin", settings().SnaptikURL)
+	postReq.Header.Set("Referer", settings().SnaptikURL+"/")
 	postReq.Header.Set("User-Agent", getUserAgent())
 
 	postResp, err := client.Do(postReq)
 	if err != nil {
-		return "", fmt.Errorf("ошибка POST-запроса к snaptik.app: %v", err)
+		return nil, fmt.Errorf("ошибка POST-запроса к snaptik.app: %v", err)
 	}
 	defer postResp.Body.Close()
 
 	if postResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неверный статус код от abc2.php: %d", postResp.StatusCode)
+		return nil, fmt.Errorf("неверный статус код от abc2.php: %d", postResp.StatusCode)
 	}
 
 	body, err := io.ReadAll(postResp.Body)
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения ответа от snaptik.app: %v", err)
+		return nil, fmt.Errorf("ошибка чтения ответа от snaptik.app: %v", err)
 	}
 
 	decryptedHTML := decryptSnaptik(string(body))
 	if decryptedHTML == "" {
-		return "", fmt.Errorf("не удалось расшифровать данные snaptik")
+		return nil, fmt.Errorf("не удалось расшифровать данные snaptik")
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(decryptedHTML))
 	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга расшифрованного HTML snaptik: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга расшифрованного HTML snaptik: %v", err)
 	}
 
 	videoURL, exists := doc.Find(".download-box > .video-links > a").Attr("href")
@@ -422,74 +582,74 @@ func getSnapsaveVideoURLTikTok(ctx context.Context, mediaURL string) (string, er
 		if !exists || videoURL == "" {
 			videoURL, exists = doc.Find("a[href*='.mp4']").Attr("href")
 			if !exists || videoURL == "" {
-				return "", fmt.Errorf("видео URL не найден в ответе snaptik")
+				return nil, fmt.Errorf("%w в ответе snaptik", ErrVideoNotFound)
 			}
 		}
 	}
 
-	return videoURL, nil
+	return snapsaveResult(doc, videoURL), nil
 }
 
-func getSnapsaveVideoURLTwitter(ctx context.Context, mediaURL string) (string, error) {
+func getSnapsaveVideoURLTwitter(ctx context.Context, mediaURL string) (*SnapsaveResponse, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
-	homeReq, err := http.NewRequestWithContext(ctx, "GET", "https://twitterdownloader.snapsave.app/", nil)
+	homeReq, err := http.NewRequestWithContext(ctx, "GET", settings().TwitterDownloaderURL+"/", nil)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса к twitterdownloader.snapsave.app: %v", err)
+		return nil, fmt.Errorf("ошибка создания запроса к twitterdownloader.snapsave.app: %v", err)
 	}
 
 	homeReq.Header.Set("User-Agent", getUserAgent())
 
 	homeResp, err := client.Do(homeReq)
 	if err != nil {
-		return "", fmt.Errorf("ошибка запроса к twitterdownloader.snapsave.app: %v", err)
+		return nil, fmt.Errorf("ошибка запроса к twitterdownloader.snapsave.app: %v", err)
 	}
 	defer homeResp.Body.Close()
 
 	if homeResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неверный статус код от twitterdownloader.snapsave.app: %d", homeResp.StatusCode)
+		return nil, fmt.Errorf("неверный статус код от twitterdownloader.snapsave.app: %d", homeResp.StatusCode)
 	}
 
 	homeDoc, err := goquery.NewDocumentFromReader(homeResp.Body)
 	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга HTML twitterdownloader.snapsave.app: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга HTML twitterdownloader.snapsave.app: %v", err)
 	}
 
 	token, exists := homeDoc.Find("input[name='token']").Attr("value")
 	if !exists || token == "" {
-		return "", fmt.Errorf("токен не найден на странице twitterdownloader.snapsave.app")
+		return nil, fmt.Errorf("токен не найден на странице twitterdownloader.snapsave.app")
 	}
 
 	formData := neturl.Values{}
 	formData.Set("url", mediaURL)
 	formData.Set("token", token)
 
-	postReq, err := http.NewRequestWithContext(ctx, "POST", "https://twitterdownloader.snapsave.app/action.php", strings.NewReader(formData.Encode()))
+	postReq, err := http.NewRequestWithContext(ctx, "POST", settings().TwitterDownloaderURL+"/action.php", strings.NewReader(formData.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания POST-запроса к twitterdownloader.snapsave.app: %v", err)
+		return nil, fmt.Errorf("ошибка создания POST-запроса к twitterdownloader.snapsave.app: %v", err)
 	}
 
 	postReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	postReq.Header.Set("Accept", "*/*")
-	postReq.Header.Set("Origin", "https://twitterdownloader.snapsave.app")
-	postReq.Header.Set("Referer", "https://twitterdownloader.snapsave.app/")
+	postReq.Header.Set("Origin", settings().TwitterDownloaderURL)
+	postReq.Header.Set("Referer", settings().TwitterDownloaderURL+"/")
 	postReq.Header.Set("User-Agent", getUserAgent())
 
 	postResp, err := client.Do(postReq)
 	if err != nil {
-		return "", fmt.Errorf("ошибка POST-запроса к twitterdownloader.snapsave.app: %v", err)
+		return nil, fmt.Errorf("ошибка POST-запроса к twitterdownloader.snapsave.app: %v", err)
 	}
 	defer postResp.Body.Close()
 
 	if postResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неверный статус код от action.php: %d", postResp.StatusCode)
+		return nil, fmt.Errorf("неверный статус код от action.php: %d", postResp.StatusCode)
 	}
 
 	body, err := io.ReadAll(postResp.Body)
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения ответа от twitterdownloader.snapsave.app: %v", err)
+		return nil, fmt.Errorf("ошибка чтения ответа от twitterdownloader.snapsave.app: %v", err)
 	}
 
 	var jsonResponse struct {
@@ -497,29 +657,29 @@ func getSnapsaveVideoURLTwitter(ctx context.Context, mediaURL string) (string, e
 	}
 
 	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return "", fmt.Errorf("ошибка парсинга JSON ответа: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга JSON ответа: %v", err)
 	}
 
 	if jsonResponse.Data == "" {
-		return "", fmt.Errorf("пустые данные в JSON ответе")
+		return nil, fmt.Errorf("пустые данные в JSON ответе")
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(jsonResponse.Data))
 	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга HTML из JSON: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга HTML из JSON: %v", err)
 	}
 
 	videoURL, exists := doc.Find("#download-block > .abuttons > a").Attr("href")
 	if !exists || videoURL == "" {
-		return "", fmt.Errorf("видео URL не найден в ответе twitterdownloader")
+		return nil, fmt.Errorf("%w в ответе twitterdownloader", ErrVideoNotFound)
 	}
 
-	return videoURL, nil
+	return snapsaveResult(doc, videoURL), nil
 }
 
 // getSnapsaveVideoURLInstagramFacebook получает URL видео для Instagram и Facebook
-func getSnapsaveVideoURLInstagramFacebook(ctx context.Context, mediaURL string) (string, error) {
-	apiURL := "https://snapsave.app/action.php?lang=en"
+func getSnapsaveVideoURLInstagramFacebook(ctx context.Context, mediaURL string) (*SnapsaveResponse, error) {
+	apiURL := settings().SnapsaveURL + "/action.php?lang=en"
 
 	formData := neturl.Values{}
 	formData.Set("url", normalizeURL(mediaURL))
@@ -530,37 +690,41 @@ func getSnapsaveVideoURLInstagramFacebook(ctx context.Context, mediaURL string)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(formData.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %v", err)
+		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", getUserAgent())
-	req.Header.Set("Referer", "https://snapsave.app/")
-	req.Header.Set("Origin", "https://snapsave.app")
+	req.Header.Set("Referer", settings().SnapsaveURL+"/")
+	req.Header.Set("Origin", settings().SnapsaveURL)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("ошибка выполнения запроса: %v", err)
+		return nil, fmt.Errorf("ошибка выполнения запроса: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неверный статус код: %d", resp.StatusCode)
+		return nil, fmt.Errorf("неверный статус код: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения ответа: %v", err)
+		return nil, fmt.Errorf("ошибка чтения ответа: %v", err)
 	}
 
 	decryptedHTML := decryptSnapSave(string(body))
 	if decryptedHTML == "" {
-		return findVideoURLWithRegex(string(body))
+		videoURL, err := findVideoURLWithRegex(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return snapsaveResult(nil, videoURL), nil
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(decryptedHTML))
 	if err != nil {
-		return "", fmt.Errorf("ошибка парсинга расшифрованного HTML: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга расшифрованного HTML: %v", err)
 	}
 
 	var videoURL string
@@ -578,7 +742,7 @@ func getSnapsaveVideoURLInstagramFacebook(ctx context.Context, mediaURL string)
 						re := regexp.MustCompile(`get_progressApi\('([^']+)'\)`)
 						matches := re.FindStringSubmatch(onclick)
 						if len(matches) > 1 && videoURL == "" {
-							videoURL = "https://snapsave.app" + matches[1]
+							videoURL = settings().SnapsaveURL + matches[1]
 						}
 					}
 				}
@@ -614,10 +778,10 @@ func getSnapsaveVideoURLInstagramFacebook(ctx context.Context, mediaURL string)
 	}
 
 	if videoURL == "" {
-		return "", fmt.Errorf("не удалось найти видео URL в расшифрованном HTML")
+		return nil, fmt.Errorf("%w в расшифрованном HTML", ErrVideoNotFound)
 	}
 
-	return videoURL, nil
+	return snapsaveResult(doc, videoURL), nil
 }
 
 func findVideoURLWithRegex(htmlContent string) (string, error) {
@@ -633,16 +797,16 @@ func findVideoURLWithRegex(htmlContent string) (string, error) {
 		if len(matches) > 1 {
 			videoURL := matches[1]
 			if strings.Contains(pattern, "get_progressApi") {
-				videoURL = "https://snapsave.app" + videoURL
+				videoURL = settings().SnapsaveURL + videoURL
 			}
 			return videoURL, nil
 		}
 	}
 
-	return "", fmt.Errorf("не удалось найти видео URL через регулярные выражения")
+	return "", fmt.Errorf("%w через регулярные выражения", ErrVideoNotFound)
 }
 
-func createUserDirectory(userID int64, platform string) (string, error) {
+func createUserDirectory(ctx context.Context, userID int64, platform string) (string, error) {
 	workDir, err := os.Getwd()
 	if err != nil {
 		workDir = "."
@@ -654,21 +818,21 @@ func createUserDirectory(userID int64, platform string) (string, error) {
 	defer tempDirMutex.Unlock()
 
 	if err := os.MkdirAll(tempDirBase, 0755); err != nil {
-		return "", fmt.Errorf("не удалось создать базовую директорию для временных файлов: %v", err)
+		return "", fmt.Errorf("%w: базовая директория временных файлов: %v", ErrStorage, err)
 	}
 
 	userDir := filepath.Join(tempDirBase, strconv.FormatInt(userID, 10))
 	if err := os.MkdirAll(userDir, 0755); err != nil {
-		return "", fmt.Errorf("не удалось создать директорию пользователя для временных файлов: %v", err)
+		return "", fmt.Errorf("%w: директория пользователя: %v", ErrStorage, err)
 	}
 
 	cacheDir := filepath.Join(tempDirBase, ".cache")
 	configDir := filepath.Join(tempDirBase, ".config")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		fmt.Printf("Предупреждение: не удалось создать cache директорию: %v\n", err)
+		logging.From(ctx).Warn("Не удалось создать cache директорию", "error", err)
 	}
 	if err := os.MkdirAll(configDir, 0755); err != nil {
-		fmt.Printf("Предупреждение: не удалось создать config директорию: %v\n", err)
+		logging.From(ctx).Warn("Не удалось создать config директорию", "error", err)
 	}
 
 	uniqueID := generateUniqueID()
@@ -683,50 +847,54 @@ func createUserDirectory(userID int64, platform string) (string, error) {
 	return outputPath, nil
 }
 
-// fallbackDownload обрабатывает скачивание видео через fallback методы
-func fallbackDownload(ctx context.Context, mediaURL string, userID int64, platform PlatformType) (string, error) {
-	switch platform {
-	case Instagram:
-		return fallbackInstagramDownload(ctx, mediaURL, userID)
-	case Twitter:
-		return fallbackTwitterDownload(ctx, mediaURL, userID)
-	case TikTok:
-		return fallbackTikTokDownload(ctx, mediaURL, userID)
-	case Facebook:
-		return fallbackFacebookDownload(ctx, mediaURL, userID)
-	default:
-		return "", fmt.Errorf("платформа %s не поддерживается в fallback режиме", platform)
+// DownloadFile скачивает файл по прямой ссылке, например файл Telegram, во временный
+// каталог пользователя с той же проверкой содержимого, что и видео с платформ
+func DownloadFile(ctx context.Context, fileURL string, userID int64) (*Media, error) {
+	outputPath, err := createUserDirectory(ctx, userID, "telegram")
+	if err != nil {
+		return nil, err
+	}
+	path, err := downloadMedia(ctx, fileURL, outputPath)
+	if err != nil {
+		return nil, classify(err)
 	}
+	return &Media{Path: path}, nil
+}
+
+func DownloadInstagramVideo(ctx context.Context, url string, userID int64) (*Media, error) {
+	return download(ctx, Instagram, url, userID)
 }
 
-func DownloadInstagramVideo(ctx context.Context, url string, userID int64) (string, error) {
-	return snapsaveDownload(ctx, url, userID)
+func DownloadTwitterVideo(ctx context.Context, url string, userID int64) (*Media, error) {
+	return download(ctx, Twitter, url, userID)
 }
 
-func DownloadTwitterVideo(ctx context.Context, url string, userID int64) (string, error) {
-	return snapsaveDownload(ctx, url, userID)
+func DownloadTikTokVideo(ctx context.Context, url string, userID int64) (*Media, error) {
+	return download(ctx, TikTok, url, userID)
 }
 
-func DownloadTikTokVideo(ctx context.Context, url string, userID int64) (string, error) {
-	return snapsaveDownload(ctx, url, userID)
+func DownloadFacebookVideo(ctx context.Context, url string, userID int64) (*Media, error) {
+	return download(ctx, Facebook, url, userID)
 }
 
-func DownloadFacebookVideo(ctx context.Context, url string, userID int64) (string, error) {
-	return snapsaveDownload(ctx, url, userID)
+func DownloadYouTubeVideo(ctx context.Context, url string, userID int64) (*Media, error) {
+	return download(ctx, YouTube, url, userID)
 }
 
-func DownloadYouTubeVideo(ctx context.Context, url string, userID int64) (string, error) {
-	outputPath, err := createUserDirectory(userID, "youtube")
+// ytDlpDownload скачивает YouTube Shorts через yt-dlp
+func ytDlpDownload(ctx context.Context, url string, userID int64) (string, error) {
+	outputPath, err := createUserDirectory(ctx, userID, "youtube")
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания директории: %v", err)
+		return "", err
 	}
 
-	if err := checkYtDlpAvailability(); err != nil {
-		return "", fmt.Errorf("yt-dlp недоступен: %v", err)
+	if err := checkYtDlpAvailability(ctx); err != nil {
+		observer.ToolFailure("yt-dlp")
+		return "", fmt.Errorf("%w: %v", ErrToolUnavailable, err)
 	}
 
 	args := []string{
-		"--max-filesize", "50M",
+		"--max-filesize", strconv.FormatInt(settings().MaxFileSize, 10),
 		"--no-playlist",
 		"--merge-output-format", "mp4",
 		"--no-cache-dir",
@@ -758,44 +926,45 @@ func DownloadYouTubeVideo(ctx context.Context, url string, userID int64) (string
 	stderrStr := stderr.String()
 
 	if runErr != nil {
-		fmt.Printf("yt-dlp failed: %v\nStdout: %s\nStderr: %s\n", runErr, stdoutStr, stderrStr)
+		logging.From(ctx).Error("yt-dlp завершился с ошибкой", "error", runErr, "stdout", stdoutStr, "stderr", stderrStr)
+		observer.ToolFailure("yt-dlp")
 
 		if strings.Contains(stderrStr, "Video unavailable") {
-			return "", fmt.Errorf("видео недоступно (возможно, удалено или приватное)")
+			return "", fmt.Errorf("%w (возможно, удалено или приватное)", ErrUnavailable)
 		}
 		if strings.Contains(stderrStr, "Private video") {
-			return "", fmt.Errorf("видео является приватным")
+			return "", ErrPrivate
 		}
 		if strings.Contains(stderrStr, "Sign in to confirm your age") {
-			return "", fmt.Errorf("видео имеет возрастные ограничения")
+			return "", ErrAgeRestricted
 		}
 		if strings.Contains(stderrStr, "This video is not available") {
-			return "", fmt.Errorf("видео недоступно в вашем регионе")
+			return "", ErrGeoBlocked
 		}
 		if strings.Contains(stderrStr, "Requested format is not available") {
-			return "", fmt.Errorf("запрашиваемый формат недоступен")
+			return "", fmt.Errorf("%w: запрашиваемый формат недоступен", ErrUnavailable)
 		}
 		if strings.Contains(stderrStr, "Sign in to confirm") || strings.Contains(stderrStr, "not a bot") {
-			return "", fmt.Errorf("YouTube требует авторизацию (bot detection), попробуйте позже")
+			return "", fmt.Errorf("YouTube: %w, попробуйте позже", ErrAuthRequired)
 		}
 		if strings.Contains(stderrStr, "Unable to extract") || strings.Contains(stderrStr, "Incomplete data") {
-			return "", fmt.Errorf("не удалось извлечь данные видео — возможно, yt-dlp устарел")
+			return "", fmt.Errorf("%w: не удалось извлечь данные видео — возможно, yt-dlp устарел", ErrToolUnavailable)
 		}
 
 		return "", fmt.Errorf("ошибка скачивания YouTube Shorts (exit: %v)", runErr)
 	}
 
 	if strings.Contains(stderrStr, "File is larger than max-filesize") {
-		return "", fmt.Errorf("файл превышает ограничение размера (50MB)")
+		return "", fmt.Errorf("%w: превышает ограничение размера (%d MB)", ErrTooLarge, settings().MaxFileSize/(1024*1024))
 	}
 	if strings.Contains(stderrStr, "Requested format is not available") {
-		return "", fmt.Errorf("подходящий формат видео не найден (возможно, все версии слишком большие)")
+		return "", fmt.Errorf("%w: подходящий формат видео не найден (возможно, все версии слишком большие)", ErrTooLarge)
 	}
 	if strings.Contains(stdoutStr, "aborting") || strings.Contains(stderrStr, "aborting") {
 		return "", fmt.Errorf("скачивание прервано (возможно, из-за превышения размера файла)")
 	}
 
-	fmt.Printf("yt-dlp успешно завершен. Stdout: %s\n", stdoutStr)
+	logging.From(ctx).Debug("yt-dlp успешно завершен", "stdout", stdoutStr)
 
 	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
 		// yt-dlp может создать файл с другим именем, ищем все файлы в директории
@@ -837,7 +1006,7 @@ func DownloadYouTubeVideo(ctx context.Context, url string, userID int64) (string
 			if !file.IsDir() && strings.HasSuffix(file.Name(), ".part") {
 				partFilePath := filepath.Join(dir, file.Name())
 				if err := os.Remove(partFilePath); err == nil {
-					fmt.Printf("Удален незавершенный файл: %s\n", partFilePath)
+					logging.From(ctx).Info("Удален незавершенный файл", "path", partFilePath)
 					partFilesFound = true
 				}
 			}
@@ -890,44 +1059,45 @@ func DownloadYouTubeVideo(ctx context.Context, url string, userID int64) (string
 		return "", fmt.Errorf("ошибка получения информации о файле: %v", err)
 	}
 
-	// Проверяем, что файл не слишком большой для Telegram (50MB лимит)
-	const maxFileSize = 50 * 1024 * 1024 // 50MB
+	// Проверяем, что файл не слишком большой для Telegram
+	maxFileSize := settings().MaxFileSize
 	if fileInfo.Size() > maxFileSize {
 		os.Remove(outputPath) // Удаляем слишком большой файл
-		return "", fmt.Errorf("файл слишком большой для отправки через Telegram (%.1f MB > 50 MB)", float64(fileInfo.Size())/(1024*1024))
+		return "", fmt.Errorf("%w для отправки через Telegram (%.1f MB > %d MB)", ErrTooLarge,
+			float64(fileInfo.Size())/(1024*1024), maxFileSize/(1024*1024))
 	}
 
 	// Проверяем, что файл не пустой
 	if fileInfo.Size() < 1024 { // Минимум 1KB
 		os.Remove(outputPath)
-		return "", fmt.Errorf("скачанный файл слишком маленький (возможно, ошибка скачивания)")
+		return "", fmt.Errorf("%w: скачанный файл слишком маленький", ErrNotMedia)
 	}
 
 	return outputPath, nil
 }
 
 // checkYtDlpAvailability проверяет доступность yt-dlp
-func checkYtDlpAvailability() error {
-	cmd := exec.Command("yt-dlp", "--version")
+func checkYtDlpAvailability(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "yt-dlp", "--version")
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("yt-dlp не установлен или недоступен: %v", err)
 	}
 
 	// Логируем версию для диагностики
-	fmt.Printf("yt-dlp version: %s\n", strings.TrimSpace(string(output)))
+	logging.From(ctx).Debug("Версия yt-dlp", "version", strings.TrimSpace(string(output)))
 	return nil
 }
 
 // fallbackInstagramDownload резервный метод для Instagram
 func fallbackInstagramDownload(ctx context.Context, url string, userID int64) (string, error) {
-	outputPath, err := createUserDirectory(userID, "instagram")
+	outputPath, err := createUserDirectory(ctx, userID, "instagram")
 	if err != nil {
 		return "", err
 	}
 
-	// Заменяем instagram.com на ddinstagram.com для легкого извлечения видео
-	ddUrl := strings.Replace(url, "instagram.com", "ddinstagram.com", 1)
+	// Заменяем instagram.com на зеркало (ddinstagram.com) для легкого извлечения видео
+	ddUrl := strings.Replace(url, "instagram.com", settings().DDInstagramHost, 1)
 
 	// Настройка HTTP-клиента с увеличенным таймаутом
 	client := &http.Client{
@@ -989,7 +1159,7 @@ func fallbackInstagramDownload(ctx context.Context, url string, userID int64) (s
 	}
 
 	if videoURL == "" {
-		return "", fmt.Errorf("не удалось найти URL видео в fallback режиме для Instagram")
+		return "", fmt.Errorf("%w в fallback режиме для Instagram", ErrVideoNotFound)
 	}
 
 	return downloadMedia(ctx, videoURL, outputPath)
@@ -997,14 +1167,14 @@ func fallbackInstagramDownload(ctx context.Context, url string, userID int64) (s
 
 // fallbackTwitterDownload резервный метод для Twitter
 func fallbackTwitterDownload(ctx context.Context, url string, userID int64) (string, error) {
-	outputPath, err := createUserDirectory(userID, "twitter")
+	outputPath, err := createUserDirectory(ctx, userID, "twitter")
 	if err != nil {
 		return "", err
 	}
 
 	// Заменяем x.com на twitter.com, а затем twitter.com на vxtwitter.com
 	url = strings.Replace(url, "x.com", "twitter.com", 1)
-	vxUrl := strings.Replace(url, "twitter.com", "vxtwitter.com", 1)
+	vxUrl := strings.Replace(url, "twitter.com", settings().VXTwitterHost, 1)
 
 	// Настройка HTTP-клиента
 	client := &http.Client{
@@ -1065,7 +1235,7 @@ func fallbackTwitterDownload(ctx context.Context, url string, userID int64) (str
 	}
 
 	if videoURL == "" {
-		return "", fmt.Errorf("не удалось найти URL видео в fallback режиме для Twitter")
+		return "", fmt.Errorf("%w в fallback режиме для Twitter", ErrVideoNotFound)
 	}
 
 	return downloadMedia(ctx, videoURL, outputPath)
@@ -1075,7 +1245,7 @@ func fallbackTwitterDownload(ctx context.Context, url string, userID int64) (str
 func fallbackTikTokDownload(ctx context.Context, url string, userID int64) (string, error) {
 
 	// Создаем выходную директорию
-	outputPath, err := createUserDirectory(userID, "tiktok")
+	outputPath, err := createUserDirectory(ctx, userID, "tiktok")
 	if err != nil {
 		return "", err
 	}
@@ -1088,7 +1258,7 @@ func fallbackTikTokDownload(ctx context.Context, url string, userID int64) (stri
 	formData := neturl.Values{}
 	formData.Set("url", url)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://tikmate.online/download", strings.NewReader(formData.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", settings().TikmateURL+"/download", strings.NewReader(formData.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("ошибка создания запроса к tikmate.online: %v", err)
 	}
@@ -1096,8 +1266,8 @@ func fallbackTikTokDownload(ctx context.Context, url string, userID int64) (stri
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", getUserAgent())
-	req.Header.Set("Origin", "https://tikmate.online")
-	req.Header.Set("Referer", "https://tikmate.online/")
+	req.Header.Set("Origin", settings().TikmateURL)
+	req.Header.Set("Referer", settings().TikmateURL+"/")
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -1159,14 +1329,7 @@ func fallbackTikTokRegexExtract(ctx context.Context, htmlContent string, outputP
 		}
 	}
 
-	return "", fmt.Errorf("не удалось найти URL видео в fallback режиме для TikTok")
-}
-
-// fallbackFacebookDownload резервный метод для Facebook (простой подход)
-func fallbackFacebookDownload(ctx context.Context, url string, userID int64) (string, error) {
-	// Для Facebook пока что просто возвращаем ошибку, так как fallback методы сложны
-	// В будущем можно добавить альтернативные API
-	return "", fmt.Errorf("facebook fallback метод пока не реализован - попробуйте позже")
+	return "", fmt.Errorf("%w в fallback режиме для TikTok", ErrVideoNotFound)
 }
 
 // downloadMedia скачивает медиа по URL и сохраняет его в outputPath
@@ -1177,7 +1340,7 @@ func downloadMedia(ctx context.Context, url, outputPath string) (string, error)
 
 	// Проверяем и исправляем относительные URL
 	if strings.HasPrefix(url, "/") {
-		url = "https://ddinstagram.com" + url
+		url = "https://" + settings().DDInstagramHost + url
 	}
 
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
@@ -1211,6 +1374,11 @@ func downloadMedia(ctx context.Context, url, outputPath string) (string, error)
 
 		resp, err := client.Do(req)
 		if err != nil {
+			// Адрес не попадает в ошибку: в нём бывают подписи CDN и токен бота
+			var urlErr *neturl.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
 			lastErr = fmt.Errorf("ошибка при скачивании видео (попытка %d): %v", attempt+1, err)
 			time.Sleep(time.Duration(attempt+1) * time.Second)
 			continue
@@ -1224,18 +1392,9 @@ func downloadMedia(ctx context.Context, url, outputPath string) (string, error)
 			continue
 		}
 
-		contentType := resp.Header.Get("Content-Type")
-		if !strings.Contains(contentType, "video/") && !strings.Contains(contentType, "application/octet-stream") && !strings.Contains(contentType, "binary/") {
-			contentLength := resp.ContentLength
-			if contentLength > 0 && contentLength < 10000 {
-				lastErr = fmt.Errorf("контент не похож на видео: тип %s, размер %d байт", contentType, contentLength)
-				continue
-			}
-		}
-
 		out, err := os.Create(outputPath)
 		if err != nil {
-			return "", fmt.Errorf("ошибка при создании файла: %v", err)
+			return "", fmt.Errorf("%w: %v", ErrStorage, err)
 		}
 
 		n, err := io.Copy(out, resp.Body)
@@ -1247,12 +1406,20 @@ func downloadMedia(ctx context.Context, url, outputPath string) (string, error)
 			continue
 		}
 
-		if n < 1024 {
+		if resp.ContentLength > 0 && n < resp.ContentLength {
 			os.Remove(outputPath)
-			lastErr = fmt.Errorf("скачанный файл слишком маленький (%d байт), возможно это не видео", n)
+			lastErr = fmt.Errorf("файл скачан не полностью: %d из %d байт", n, resp.ContentLength)
 			continue
 		}
 
+		// Страница ошибки или капчи вместо видео не исправится повтором:
+		// ошибка сразу передаёт скачивание следующему провайдеру цепочки
+		kind, err := validateMedia(outputPath)
+		if err != nil {
+			os.Remove(outputPath)
+			return "", fmt.Errorf("%w (Content-Type %q, %d байт)", err, resp.Header.Get("Content-Type"), n)
+		}
+		logging.From(ctx).Debug("Файл проверен", "format", kind, "bytes", n)
 		return outputPath, nil
 	}
 
@@ -0,0 +1,46 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Ошибки, по которым вызывающий код может определить причину неудачи.
+// Провайдеры оборачивают их через %w, дополняя подробностями.
+var (
+	ErrVideoNotFound     = errors.New("видео URL не найден")
+	ErrUnavailable       = errors.New("видео недоступно")
+	ErrPrivate           = errors.New("видео является приватным")
+	ErrAgeRestricted     = errors.New("видео имеет возрастные ограничения")
+	ErrGeoBlocked        = errors.New("видео недоступно в вашем регионе")
+	ErrTooLarge          = errors.New("файл слишком большой")
+	ErrNotMedia          = errors.New("скачанный файл не является видео или изображением")
+	ErrAuthRequired      = errors.New("требуется авторизация (bot detection)")
+	ErrToolUnavailable   = errors.New("утилита скачивания недоступна")
+	ErrTimeout           = errors.New("превышено время скачивания")
+	ErrProvidersDisabled = errors.New("все провайдеры отключены администратором")
+	ErrConversion        = errors.New("не удалось преобразовать видео")
+	ErrUnsupported       = errors.New("платформа не поддерживается")
+	ErrStorage           = errors.New("не удалось сохранить файл")
+	ErrProviderFailed    = errors.New("сервис скачивания вернул ошибку")
+)
+
+// knownErrors — ошибки, причина которых понятна вызывающему коду без разбора текста
+var knownErrors = []error{
+	ErrVideoNotFound, ErrUnavailable, ErrPrivate, ErrAgeRestricted, ErrGeoBlocked,
+	ErrTooLarge, ErrNotMedia, ErrAuthRequired, ErrToolUnavailable, ErrTimeout,
+	ErrProvidersDisabled, ErrConversion, ErrUnsupported, ErrStorage, ErrProviderFailed,
+	context.Canceled, context.DeadlineExceeded,
+}
+
+// classify оборачивает ошибку без известной причины — сетевую ошибку, неожиданный
+// статус или разметку ответа сервиса — в ErrProviderFailed
+func classify(err error) error {
+	for _, known := range knownErrors {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrProviderFailed, err)
+}
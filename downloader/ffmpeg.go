@@ -0,0 +1,138 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout — сколько ждать ffprobe; он читает только заголовки файла
+const probeTimeout = 15 * time.Second
+
+// Слоты ffmpeg ограничивают число одновременно работающих процессов
+// независимо от числа одновременных скачиваний: перекодирование загружает
+// процессор, а скачивание — только сеть
+var (
+	ffmpegMu    sync.Mutex
+	ffmpegSlots = make(chan struct{}, DefaultSettings().FFmpegWorkers)
+)
+
+// setFFmpegWorkers меняет число слотов ffmpeg. Процессы, занявшие слот
+// до изменения, освобождают его в старом наборе.
+func setFFmpegWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	ffmpegMu.Lock()
+	defer ffmpegMu.Unlock()
+	if cap(ffmpegSlots) != n {
+		ffmpegSlots = make(chan struct{}, n)
+	}
+}
+
+// acquireFFmpeg ждёт свободный слот ffmpeg или отмены контекста
+func acquireFFmpeg(ctx context.Context) (release func(), err error) {
+	ffmpegMu.Lock()
+	slots := ffmpegSlots
+	ffmpegMu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+	}
+
+	start := time.Now()
+	select {
+	case slots <- struct{}{}:
+		logging.From(ctx).Debug("Дождался свободного слота ffmpeg", "wait", time.Since(start))
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runFFmpeg запускает ffmpeg с перезаписью выходного файла и выводом только ошибок.
+// Перед запуском занимает слот ffmpeg; при отмене контекста процесс завершается,
+// и возвращается ошибка контекста.
+func runFFmpeg(ctx context.Context, args ...string) error {
+	release, err := acquireFFmpeg(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-y", "-v", "error"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		observer.ToolFailure("ffmpeg")
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ProbeVideo определяет размеры, длительность и кодеки видео: MP4 разбирается
+// напрямую, остальные форматы — через ffprobe. Поля, которые определить
+// не удалось, остаются нулевыми.
+func ProbeVideo(path string) VideoInfo {
+	info, err := ProbeMP4(path)
+	if err == nil {
+		return info
+	}
+	if !errors.Is(err, ErrNotMP4) {
+		slog.Debug("Не удалось разобрать MP4, используется ffprobe", "path", path, "error", err)
+	}
+	return ffprobeVideo(path)
+}
+
+// ffprobeVideo определяет размеры, длительность и кодеки видео через ffprobe.
+// ffprobe не декодирует кадры, поэтому слот ffmpeg не занимает.
+func ffprobeVideo(path string) VideoInfo {
+	type probeOutput struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "quiet", "-print_format", "json", "-show_streams", "-show_format", path).Output()
+	if err != nil {
+		observer.ToolFailure("ffprobe")
+		return VideoInfo{}
+	}
+	var data probeOutput
+	if err := json.Unmarshal(out, &data); err != nil {
+		return VideoInfo{}
+	}
+
+	var info VideoInfo
+	for _, s := range data.Streams {
+		switch {
+		case s.CodecType == "video" && info.VideoCodec == "":
+			info.Width, info.Height, info.VideoCodec = s.Width, s.Height, s.CodecName
+		case s.CodecType == "audio" && info.AudioCodec == "":
+			info.AudioCodec = s.CodecName
+		}
+	}
+	if seconds, err := strconv.ParseFloat(data.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	return info
+}
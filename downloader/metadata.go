@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// metadataTimeout — сколько ждать сведений о посте; подпись не должна задерживать отправку
+const metadataTimeout = 10 * time.Second
+
+// Metadata — сведения о посте для подписи к видео. Любое поле может быть пустым.
+type Metadata struct {
+	Author      string // отображаемое имя автора
+	Handle      string // имя пользователя без @
+	Description string // текст поста или название видео
+}
+
+// fetchMetadata получает автора и описание поста через oEmbed и открытые зеркала.
+// Ошибки не критичны: без метаданных подпись строится из ссылки.
+func fetchMetadata(ctx context.Context, platform PlatformType, mediaURL string) Metadata {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	var meta Metadata
+	var err error
+	switch platform {
+	case YouTube:
+		meta, err = oembedMetadata(ctx, "https://www.youtube.com/oembed?format=json&url=", mediaURL)
+	case TikTok:
+		meta, err = oembedMetadata(ctx, "https://www.tiktok.com/oembed?url=", mediaURL)
+	case Twitter:
+		meta, err = vxtwitterMetadata(ctx, mediaURL)
+	case Instagram:
+		// Зеркало отдаёт ботам страницу с og-тегами: автор в og:title, подпись в og:description
+		meta, err = openGraphMetadata(ctx, strings.Replace(mediaURL, "instagram.com", settings().DDInstagramHost, 1))
+	case Facebook:
+		meta, err = openGraphMetadata(ctx, mediaURL)
+	}
+	if err != nil {
+		logging.From(ctx).Debug("Не удалось получить описание поста", "platform", platform, "error", err)
+	}
+
+	meta.Author = strings.TrimSpace(meta.Author)
+	meta.Handle = strings.TrimPrefix(strings.TrimSpace(meta.Handle), "@")
+	meta.Description = strings.TrimSpace(meta.Description)
+	return meta
+}
+
+// oembedMetadata запрашивает oEmbed: название видео и автора
+func oembedMetadata(ctx context.Context, endpoint, mediaURL string) (Metadata, error) {
+	var data struct {
+		Title          string `json:"title"`
+		AuthorName     string `json:"author_name"`
+		AuthorURL      string `json:"author_url"`
+		AuthorUniqueID string `json:"author_unique_id"` // только TikTok
+	}
+	if err := getJSON(ctx, endpoint+neturl.QueryEscape(mediaURL), &data); err != nil {
+		return Metadata{}, err
+	}
+
+	handle := data.AuthorUniqueID
+	if handle == "" {
+		// YouTube отдаёт ссылку на канал вида https://www.youtube.com/@handle
+		if _, h, ok := strings.Cut(data.AuthorURL, "/@"); ok {
+			handle = h
+		}
+	}
+	return Metadata{Author: data.AuthorName, Handle: handle, Description: data.Title}, nil
+}
+
+// vxtwitterMetadata получает текст твита и автора через API зеркала vxtwitter
+func vxtwitterMetadata(ctx context.Context, mediaURL string) (Metadata, error) {
+	u, err := neturl.Parse(mediaURL)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var data struct {
+		Text           string `json:"text"`
+		UserName       string `json:"user_name"`
+		UserScreenName string `json:"user_screen_name"`
+	}
+	apiURL := "https://api." + settings().VXTwitterHost + u.Path
+	if err := getJSON(ctx, apiURL, &data); err != nil {
+		return Metadata{}, err
+	}
+	return Metadata{Author: data.UserName, Handle: data.UserScreenName, Description: data.Text}, nil
+}
+
+// openGraphMetadata читает og:title и og:description страницы поста
+func openGraphMetadata(ctx context.Context, pageURL string) (Metadata, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return Metadata{}, err
+	}
+	// Страницы с og-тегами отдаются ботам превью ссылок
+	req.Header.Set("User-Agent", "TelegramBot (like TwitterBot)")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("неверный статус код: %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return Metadata{}, err
+	}
+	meta := func(property string) string {
+		content, _ := doc.Find(`meta[property="` + property + `"]`).Attr("content")
+		return content
+	}
+
+	title := meta("og:title")
+	result := Metadata{Author: title, Description: meta("og:description")}
+	if strings.HasPrefix(title, "@") && !strings.Contains(title, " ") {
+		result = Metadata{Handle: title, Description: result.Description}
+	}
+	return result, nil
+}
+
+// getJSON выполняет GET-запрос и разбирает JSON-ответ
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", getUserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("неверный статус код: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
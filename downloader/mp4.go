@@ -0,0 +1,46 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"os"
+	"time"
+)
+
+// remuxTimeout — сколько ждать перепаковки видео; без неё видео отправится как есть
+const remuxTimeout = time.Minute
+
+// Remux — шаг, который перепаковывает MP4 без перекодирования так, чтобы moov шёл
+// в начале файла. Шаг необязательный: при ошибке видео отправится в исходном виде.
+func Remux() Stage {
+	return Stage{Name: "remux", Timeout: remuxTimeout, Optional: true, Run: ensureFaststart}
+}
+
+// ensureFaststart перепаковывает MP4, у которого moov записан после данных
+func ensureFaststart(ctx context.Context, job *Job) error {
+	logger := logging.From(ctx)
+
+	info, err := ProbeMP4(job.Media.Path)
+	switch {
+	case errors.Is(err, ErrNotMP4):
+		return nil
+	case err != nil:
+		logger.Debug("Не удалось проверить структуру MP4", "error", err)
+		return nil
+	case info.Faststart:
+		return nil
+	}
+
+	start := time.Now()
+	tmp := job.Output(".faststart.mp4")
+	if err := runFFmpeg(ctx, "-i", job.Media.Path, "-map", "0", "-c", "copy", "-movflags", "+faststart", tmp); err != nil {
+		return fmt.Errorf("перепаковка для потокового воспроизведения: %w", err)
+	}
+	if err := os.Rename(tmp, job.Media.Path); err != nil {
+		return fmt.Errorf("замена видео перепакованным: %w", err)
+	}
+	logger.Info("Видео перепаковано: moov перенесён в начало", "elapsed", time.Since(start))
+	return nil
+}
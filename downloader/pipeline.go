@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"context"
+	"goland/VideoSaverBot/logging"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Stage — шаг обработки скачанного файла: перепаковка, перекодирование,
+// вырезание фрагмента, извлечение звука, обложка, запись метаданных
+type Stage struct {
+	Name     string
+	Timeout  time.Duration // ограничение времени шага; 0 — только общий таймаут
+	Optional bool          // ошибка шага записывается в лог, обработка продолжается
+	Run      func(ctx context.Context, job *Job) error
+}
+
+// Job — файл, проходящий через конвейер обработки
+type Job struct {
+	Media *Media
+	temp  []string
+}
+
+// Output возвращает путь для результата шага рядом с обрабатываемым файлом.
+// Файл удаляется после конвейера, если шаг не сделал его основным через Replace.
+func (j *Job) Output(suffix string) string {
+	path := strings.TrimSuffix(j.Media.Path, filepath.Ext(j.Media.Path)) + suffix
+	j.temp = append(j.temp, path)
+	return path
+}
+
+// Replace заменяет обрабатываемый файл результатом шага; прежний файл удаляется
+func (j *Job) Replace(path string) {
+	if path != j.Media.Path {
+		os.Remove(j.Media.Path)
+	}
+	j.Media.Path = path
+}
+
+// cleanup удаляет промежуточные файлы шагов, кроме итогового файла и обложки
+func (j *Job) cleanup() {
+	for _, path := range j.temp {
+		if path != j.Media.Path && path != j.Media.Thumbnail {
+			os.Remove(path)
+		}
+	}
+}
+
+// Pipeline — шаги обработки, которые выполняются по порядку
+type Pipeline []Stage
+
+// Run обрабатывает файл media шагами конвейера. Каждый шаг получает собственный
+// таймаут; ошибка обязательного шага или отмена контекста прерывают обработку.
+// Промежуточные файлы удаляются в любом случае, а итоговый файл при ошибке
+// остаётся вызывающему коду.
+func (p Pipeline) Run(ctx context.Context, media *Media) error {
+	job := &Job{Media: media}
+	defer job.cleanup()
+
+	for _, stage := range p {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		sctx := logging.With(ctx, "stage", stage.Name)
+		logger := logging.From(sctx)
+		cancel := context.CancelFunc(func() {})
+		if stage.Timeout > 0 {
+			sctx, cancel = context.WithTimeout(sctx, stage.Timeout)
+		}
+
+		start := time.Now()
+		err := stage.Run(sctx, job)
+		cancel()
+		switch {
+		case err == nil:
+			logger.Debug("Шаг обработки выполнен", "elapsed", time.Since(start))
+		case stage.Optional && ctx.Err() == nil:
+			logger.Warn("Необязательный шаг обработки не выполнен", "error", err, "elapsed", time.Since(start))
+		default:
+			logger.Debug("Шаг обработки завершился ошибкой", "error", err, "elapsed", time.Since(start))
+			return err
+		}
+	}
+	return nil
+}
+
+// PostProcessing — обработка каждого скачанного видео перед отправкой. Выполняется
+// после освобождения слота скачивания и ограничивается только слотами ffmpeg.
+var PostProcessing = Pipeline{Remux(), DetectAnimation(), Thumbnail()}
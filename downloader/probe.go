@@ -0,0 +1,448 @@
+package downloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// maxMoovSize — ограничение на размер атома moov, который читается в память.
+// Индекс видео в несколько минут занимает сотни килобайт.
+const maxMoovSize = 64 * 1024 * 1024
+
+// ErrNotMP4 возвращается для файлов, которые не являются контейнером ISO BMFF (MP4, MOV)
+var ErrNotMP4 = errors.New("файл не в формате MP4")
+
+// VideoInfo — параметры видео, которые передаются Telegram при отправке
+type VideoInfo struct {
+	Width, Height int           // размеры при показе, с учётом поворота
+	Rotation      int           // поворот из матрицы дорожки: 0, 90, 180 или 270 градусов
+	Duration      time.Duration // 0, если длительность не записана
+	VideoCodec    string        // тип описания дорожки, например avc1, hvc1, av01
+	AudioCodec    string        // например mp4a, Opus
+	Faststart     bool          // moov идёт перед mdat, видео можно смотреть во время загрузки
+}
+
+// Seconds возвращает длительность в целых секундах для параметра duration Bot API
+func (v VideoInfo) Seconds() int {
+	if v.Duration <= 0 {
+		return 0
+	}
+	return max(1, int(v.Duration.Round(time.Second)/time.Second))
+}
+
+// firstBoxTypes — атомы, с которых может начинаться MP4 или MOV
+var firstBoxTypes = map[string]bool{"ftyp": true, "wide": true, "free": true, "skip": true, "moov": true, "mdat": true}
+
+// ProbeMP4 читает параметры видео из атомов MP4 без внешних утилит: размеры с учётом
+// матрицы поворота, длительность, кодеки и расположение moov. Для файлов другого
+// формата возвращает ErrNotMP4.
+func ProbeMP4(path string) (VideoInfo, error) {
+	moov, faststart, err := readMoov(path)
+	if err != nil {
+		return VideoInfo{}, err
+	}
+	info := parseMoov(moov)
+	info.Faststart = faststart
+	return info, nil
+}
+
+// readMoov читает атом moov и сообщает, идёт ли он перед mdat
+func readMoov(path string) (moov []byte, faststart bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	mdatSeen := false
+	for first := true; moov == nil || !mdatSeen; first = false {
+		boxType, size, err := readBoxHeader(f)
+		if err == io.EOF && !first {
+			break
+		}
+		if err != nil || (first && !firstBoxTypes[boxType]) {
+			if first {
+				return nil, false, ErrNotMP4
+			}
+			return nil, false, err
+		}
+
+		switch {
+		case boxType == "moov":
+			if size < 0 || size > maxMoovSize {
+				return nil, false, fmt.Errorf("атом moov слишком большой: %d байт", size)
+			}
+			moov = make([]byte, size)
+			if _, err := io.ReadFull(f, moov); err != nil {
+				return nil, false, fmt.Errorf("не удалось прочитать moov: %w", err)
+			}
+			faststart = !mdatSeen
+			continue
+		case boxType == "mdat":
+			mdatSeen = true
+		}
+
+		if size < 0 { // атом до конца файла
+			break
+		}
+		if _, err := f.Seek(size, io.SeekCurrent); err != nil {
+			return nil, false, err
+		}
+	}
+	if moov == nil {
+		return nil, false, errors.New("в файле нет атома moov")
+	}
+	return moov, faststart, nil
+}
+
+// readBoxHeader читает заголовок атома и возвращает его тип и размер содержимого;
+// -1 означает атом до конца файла
+func readBoxHeader(r io.Reader) (string, int64, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[:4]))
+	boxType := string(header[4:8])
+
+	headerSize := int64(8)
+	switch size {
+	case 0:
+		return boxType, -1, nil
+	case 1: // 64-битный размер сразу за заголовком
+		if _, err := io.ReadFull(r, header[8:16]); err != nil {
+			return "", 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:16]))
+		headerSize = 16
+	}
+	if size < headerSize {
+		return "", 0, fmt.Errorf("некорректный размер атома %q: %d", boxType, size)
+	}
+	return boxType, size - headerSize, nil
+}
+
+// eachBox вызывает fn для каждого вложенного атома; повреждённый хвост пропускается
+func eachBox(data []byte, fn func(boxType string, body []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		boxType := string(data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return
+		}
+		fn(boxType, data[headerSize:size])
+		data = data[size:]
+	}
+}
+
+// mp4Track — сведения о дорожке из trak
+type mp4Track struct {
+	handler       string // vide, soun
+	codec         string
+	width, height int
+	rotation      int
+	duration      time.Duration
+}
+
+func parseMoov(moov []byte) VideoInfo {
+	var info VideoInfo
+	var timescale uint32
+	var fragmentDuration uint64
+	var longest time.Duration
+
+	eachBox(moov, func(boxType string, body []byte) {
+		switch boxType {
+		case "mvhd":
+			var duration uint64
+			timescale, duration = parseTimescale(body)
+			info.Duration = scaleDuration(duration, timescale)
+		case "mvex":
+			// Во фрагментированных MP4 длительность mvhd нулевая, полная — в mehd
+			eachBox(body, func(boxType string, body []byte) {
+				if boxType == "mehd" && len(body) >= 8 {
+					if body[0] == 1 && len(body) >= 12 {
+						fragmentDuration = binary.BigEndian.Uint64(body[4:12])
+					} else {
+						fragmentDuration = uint64(binary.BigEndian.Uint32(body[4:8]))
+					}
+				}
+			})
+		case "trak":
+			track := parseTrak(body)
+			longest = max(longest, track.duration)
+			switch {
+			case track.handler == "vide" && info.VideoCodec == "":
+				info.VideoCodec = track.codec
+				info.Width, info.Height, info.Rotation = track.width, track.height, track.rotation
+			case track.handler == "soun" && info.AudioCodec == "":
+				info.AudioCodec = track.codec
+			}
+		}
+	})
+
+	if info.Duration == 0 {
+		info.Duration = scaleDuration(fragmentDuration, timescale)
+	}
+	if info.Duration == 0 {
+		info.Duration = longest
+	}
+	return info
+}
+
+func parseTrak(trak []byte) mp4Track {
+	var track mp4Track
+	var codedWidth, codedHeight int
+
+	eachBox(trak, func(boxType string, body []byte) {
+		switch boxType {
+		case "tkhd":
+			track.width, track.height, track.rotation = parseTkhd(body)
+		case "mdia":
+			eachBox(body, func(boxType string, body []byte) {
+				switch boxType {
+				case "mdhd":
+					timescale, duration := parseTimescale(body)
+					track.duration = scaleDuration(duration, timescale)
+				case "hdlr":
+					if len(body) >= 12 {
+						track.handler = string(body[8:12])
+					}
+				case "minf":
+					track.codec, codedWidth, codedHeight = parseSampleEntry(body)
+				}
+			})
+		}
+	})
+
+	// Размеры из tkhd — до поворота; если их нет, берутся из описания кодека
+	if track.width == 0 || track.height == 0 {
+		track.width, track.height = codedWidth, codedHeight
+	}
+	if track.rotation == 90 || track.rotation == 270 {
+		track.width, track.height = track.height, track.width
+	}
+	return track
+}
+
+// parseTkhd возвращает размеры дорожки и поворот из матрицы преобразования
+func parseTkhd(body []byte) (width, height, rotation int) {
+	matrixOffset := 40
+	if len(body) > 0 && body[0] == 1 {
+		matrixOffset = 52
+	}
+	if len(body) < matrixOffset+44 {
+		return 0, 0, 0
+	}
+
+	// Матрица 3×3 из чисел 16.16: a b u / c d v / x y w
+	matrix := body[matrixOffset : matrixOffset+36]
+	a := int32(binary.BigEndian.Uint32(matrix[0:4]))
+	b := int32(binary.BigEndian.Uint32(matrix[4:8]))
+	d := int32(binary.BigEndian.Uint32(matrix[16:20]))
+	switch {
+	case a == 0 && d == 0 && b > 0:
+		rotation = 90
+	case a == 0 && d == 0 && b < 0:
+		rotation = 270
+	case a < 0 && d < 0:
+		rotation = 180
+	}
+
+	width = int(binary.BigEndian.Uint32(body[matrixOffset+36:]) >> 16)
+	height = int(binary.BigEndian.Uint32(body[matrixOffset+40:]) >> 16)
+	return width, height, rotation
+}
+
+// parseSampleEntry находит в minf/stbl/stsd первое описание кодека и, для видео,
+// его размеры
+func parseSampleEntry(minf []byte) (codec string, width, height int) {
+	eachBox(minf, func(boxType string, body []byte) {
+		if boxType != "stbl" {
+			return
+		}
+		eachBox(body, func(boxType string, body []byte) {
+			// stsd: версия и флаги, число описаний, затем сами описания
+			if boxType != "stsd" || len(body) < 8 {
+				return
+			}
+			eachBox(body[8:], func(boxType string, entry []byte) {
+				if codec != "" {
+					return
+				}
+				codec = boxType
+				// Видео: 6 байт резерва, индекс данных, 16 байт резерва, ширина и высота
+				if len(entry) >= 28 {
+					width = int(binary.BigEndian.Uint16(entry[24:26]))
+					height = int(binary.BigEndian.Uint16(entry[26:28]))
+				}
+			})
+		})
+	})
+	return codec, width, height
+}
+
+// parseTimescale читает timescale и длительность из mvhd или mdhd
+func parseTimescale(body []byte) (timescale uint32, duration uint64) {
+	if len(body) > 0 && body[0] == 1 {
+		if len(body) < 32 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(body[20:24]), binary.BigEndian.Uint64(body[24:32])
+	}
+	if len(body) < 20 {
+		return 0, 0
+	}
+	duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+	if duration == 0xFFFFFFFF { // длительность неизвестна
+		duration = 0
+	}
+	return binary.BigEndian.Uint32(body[12:16]), duration
+}
+
+func scaleDuration(duration uint64, timescale uint32) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// maxKeyframeSamples — сколько кадров видеодорожки принимается из stsz при поиске
+// ключевого кадра: несколько суток видео с частотой 60 кадров в секунду
+const maxKeyframeSamples = 1 << 24
+
+// MP4KeyframeBefore возвращает время последнего ключевого кадра видеодорожки MP4
+// не позже at по таблицам stss, stts и stsz. ok == false без ошибки — ключевой кадр
+// неизвестен, например во фрагментированном MP4 или при повреждённых таблицах.
+func MP4KeyframeBefore(path string, at time.Duration) (keyframe time.Duration, ok bool, err error) {
+	moov, _, err := readMoov(path)
+	if err != nil {
+		return 0, false, err
+	}
+
+	found := false
+	eachBox(moov, func(boxType string, body []byte) {
+		if boxType != "trak" || found {
+			return
+		}
+		var handler string
+		var timescale uint32
+		var stbl []byte
+		eachBox(body, func(boxType string, body []byte) {
+			if boxType != "mdia" {
+				return
+			}
+			eachBox(body, func(boxType string, body []byte) {
+				switch boxType {
+				case "mdhd":
+					timescale, _ = parseTimescale(body)
+				case "hdlr":
+					if len(body) >= 12 {
+						handler = string(body[8:12])
+					}
+				case "minf":
+					eachBox(body, func(boxType string, body []byte) {
+						if boxType == "stbl" {
+							stbl = body
+						}
+					})
+				}
+			})
+		})
+		if handler == "vide" && timescale > 0 && stbl != nil {
+			found = true
+			keyframe, ok = parseKeyframe(stbl, timescale, at)
+		}
+	})
+	return keyframe, ok, nil
+}
+
+// parseKeyframe находит последний ключевой кадр не позже at: номера ключевых кадров
+// берутся из stss, их время — из длительностей кадров в stts, число кадров — из stsz.
+// Без stss все кадры ключевые. Записи stts обрабатываются целиком, поэтому время работы
+// зависит от размера таблиц, а не от числа кадров, записанного в файле.
+func parseKeyframe(stbl []byte, timescale uint32, at time.Duration) (time.Duration, bool) {
+	var stts, stss, stsz []byte
+	hasStss := false
+	eachBox(stbl, func(boxType string, body []byte) {
+		switch boxType {
+		case "stts":
+			stts = body
+		case "stss":
+			stss, hasStss = body, true
+		case "stsz":
+			stsz = body
+		}
+	})
+	if len(stts) < 8 || len(stsz) < 12 || (hasStss && len(stss) < 8) {
+		return 0, false
+	}
+	total := uint64(binary.BigEndian.Uint32(stsz[8:12]))
+	if total > maxKeyframeSamples {
+		total = maxKeyframeSamples
+	}
+
+	// Номера ключевых кадров (с единицы) должны строго возрастать
+	var sync []uint64
+	if hasStss {
+		count := uint64(binary.BigEndian.Uint32(stss[4:8]))
+		for i := uint64(0); i < count && 8+i*4+4 <= uint64(len(stss)); i++ {
+			sample := uint64(binary.BigEndian.Uint32(stss[8+i*4:]))
+			if sample == 0 || (len(sync) > 0 && sample <= sync[len(sync)-1]) {
+				return 0, false
+			}
+			sync = append(sync, sample)
+		}
+	}
+
+	limit := uint64(at.Seconds() * float64(timescale))
+	entries := uint64(binary.BigEndian.Uint32(stts[4:8]))
+	var last, decodeTime uint64
+	found := false
+	sample, next := uint64(1), 0
+	for i := uint64(0); i < entries && 8+i*8+8 <= uint64(len(stts)) && sample <= total; i++ {
+		count := uint64(binary.BigEndian.Uint32(stts[8+i*8:]))
+		if count > total-sample+1 {
+			count = total - sample + 1
+		}
+		delta := uint64(binary.BigEndian.Uint32(stts[8+i*8+4:]))
+
+		// Кадры записи, которые начинаются не позже at; decodeTime <= limit
+		within := count
+		if delta > 0 && (limit-decodeTime)/delta+1 < count {
+			within = (limit-decodeTime)/delta + 1
+		}
+		if hasStss {
+			for next < len(sync) && sync[next] < sample+within {
+				last, found = decodeTime+(sync[next]-sample)*delta, true
+				next++
+			}
+		} else if within > 0 {
+			last, found = decodeTime+(within-1)*delta, true
+		}
+
+		if within < count || (hasStss && next == len(sync)) {
+			break
+		}
+		sample += count
+		decodeTime += count * delta
+		if decodeTime > limit {
+			break
+		}
+	}
+	return scaleDuration(last, timescale), found
+}
@@ -0,0 +1,238 @@
+package downloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// box собирает атом MP4 из типа и содержимого
+func box(boxType string, parts ...[]byte) []byte {
+	var body []byte
+	for _, part := range parts {
+		body = append(body, part...)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, boxType...), body...)
+}
+
+func u32s(values ...uint32) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.BigEndian.AppendUint32(out, v)
+	}
+	return out
+}
+
+// Матрицы tkhd в формате 16.16: a b u / c d v / x y w
+var (
+	identityMatrix = u32s(0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000)
+	rotate90Matrix = u32s(0, 0x10000, 0, 0xFFFF0000, 0, 0, 0, 0, 0x40000000)
+	rotate180      = u32s(0xFFFF0000, 0, 0, 0, 0xFFFF0000, 0, 0, 0, 0x40000000)
+)
+
+// tkhd — заголовок дорожки версии 0 с матрицей и размерами
+func tkhd(matrix []byte, width, height uint32) []byte {
+	return box("tkhd", make([]byte, 40), matrix, u32s(width<<16, height<<16))
+}
+
+// mdhd — заголовок медиаданных версии 0
+func mdhd(timescale, duration uint32) []byte {
+	return box("mdhd", u32s(0, 0, 0, timescale, duration, 0))
+}
+
+func hdlr(handler string) []byte {
+	return box("hdlr", u32s(0, 0), []byte(handler), make([]byte, 12))
+}
+
+// sampleEntry — описание кодека в stsd; у видео есть размеры кадра
+func sampleEntry(codec string, width, height uint16) []byte {
+	entry := make([]byte, 78)
+	binary.BigEndian.PutUint16(entry[24:], width)
+	binary.BigEndian.PutUint16(entry[26:], height)
+	return box(codec, entry)
+}
+
+func trak(header []byte, handler, codec string, width, height uint16, tables ...[]byte) []byte {
+	stbl := append([][]byte{box("stsd", u32s(0, 1), sampleEntry(codec, width, height))}, tables...)
+	return box("trak", header,
+		box("mdia", mdhd(1000, 10000), hdlr(handler), box("minf", box("stbl", stbl...))))
+}
+
+func moov(traks ...[]byte) []byte {
+	mvhd := box("mvhd", u32s(0, 0, 0, 1000, 10000), make([]byte, 80))
+	return box("moov", append([][]byte{mvhd}, traks...)...)
+}
+
+var (
+	ftyp = box("ftyp", []byte("isom"), u32s(0x200), []byte("isomiso2avc1mp41"))
+	mdat = box("mdat", make([]byte, 32))
+)
+
+func writeFile(t *testing.T, data ...[]byte) string {
+	t.Helper()
+	var content []byte
+	for _, part := range data {
+		content = append(content, part...)
+	}
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProbeMP4(t *testing.T) {
+	audio := trak(tkhd(identityMatrix, 0, 0), "soun", "mp4a", 0, 0)
+
+	tests := []struct {
+		name string
+		file [][]byte
+		want VideoInfo
+	}{
+		{
+			name: "landscape",
+			file: [][]byte{ftyp, moov(trak(tkhd(identityMatrix, 1920, 1080), "vide", "avc1", 1920, 1080), audio), mdat},
+			want: VideoInfo{Width: 1920, Height: 1080, Duration: 10 * time.Second, VideoCodec: "avc1", AudioCodec: "mp4a", Faststart: true},
+		},
+		{
+			name: "rotated 90",
+			file: [][]byte{ftyp, moov(trak(tkhd(rotate90Matrix, 1920, 1080), "vide", "avc1", 1920, 1080)), mdat},
+			want: VideoInfo{Width: 1080, Height: 1920, Rotation: 90, Duration: 10 * time.Second, VideoCodec: "avc1", Faststart: true},
+		},
+		{
+			name: "rotated 180",
+			file: [][]byte{ftyp, moov(trak(tkhd(rotate180, 1280, 720), "vide", "hvc1", 1280, 720)), mdat},
+			want: VideoInfo{Width: 1280, Height: 720, Rotation: 180, Duration: 10 * time.Second, VideoCodec: "hvc1", Faststart: true},
+		},
+		{
+			name: "truncated tkhd uses sample entry size",
+			file: [][]byte{ftyp, moov(trak(box("tkhd", make([]byte, 50)), "vide", "avc1", 640, 360)), mdat},
+			want: VideoInfo{Width: 640, Height: 360, Duration: 10 * time.Second, VideoCodec: "avc1", Faststart: true},
+		},
+		{
+			name: "moov after mdat",
+			file: [][]byte{ftyp, mdat, moov(trak(tkhd(identityMatrix, 720, 1280), "vide", "avc1", 720, 1280))},
+			want: VideoInfo{Width: 720, Height: 1280, Duration: 10 * time.Second, VideoCodec: "avc1"},
+		},
+		{
+			name: "truncated trak keeps parsed fields",
+			file: [][]byte{ftyp, moov(audio, trak(tkhd(identityMatrix, 1920, 1080), "vide", "avc1", 1920, 1080)[:60]), mdat},
+			want: VideoInfo{Duration: 10 * time.Second, AudioCodec: "mp4a", Faststart: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProbeMP4(writeFile(t, tt.file...))
+			if err != nil {
+				t.Fatalf("ProbeMP4: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ProbeMP4 = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeMP4Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    [][]byte
+		notMP4  bool
+		wantErr bool
+	}{
+		{name: "html page", file: [][]byte{[]byte("<!DOCTYPE html><html><body>Error</body></html>")}, notMP4: true},
+		{name: "empty file", file: nil, notMP4: true},
+		{name: "no moov", file: [][]byte{ftyp, mdat}, wantErr: true},
+		{name: "moov cut short", file: [][]byte{ftyp, moov(trak(tkhd(identityMatrix, 1, 1), "vide", "avc1", 1, 1))[:40]}, wantErr: true},
+		{name: "box size below header", file: [][]byte{ftyp, u32s(4), []byte("free")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ProbeMP4(writeFile(t, tt.file...))
+			switch {
+			case tt.notMP4 && !errors.Is(err, ErrNotMP4):
+				t.Errorf("ProbeMP4 error = %v, want ErrNotMP4", err)
+			case tt.wantErr && (err == nil || errors.Is(err, ErrNotMP4)):
+				t.Errorf("ProbeMP4 error = %v, want structural error", err)
+			}
+		})
+	}
+}
+
+func TestParseTkhd(t *testing.T) {
+	tests := []struct {
+		name                    string
+		body                    []byte
+		width, height, rotation int
+	}{
+		{"identity", tkhd(identityMatrix, 640, 480)[8:], 640, 480, 0},
+		{"rotate 90", tkhd(rotate90Matrix, 640, 480)[8:], 640, 480, 90},
+		{"rotate 270", tkhd(u32s(0, 0xFFFF0000, 0, 0x10000, 0, 0, 0, 0, 0x40000000), 640, 480)[8:], 640, 480, 270},
+		{"version 1", append([]byte{1}, append(make([]byte, 51), tkhd(identityMatrix, 320, 240)[48:]...)...), 320, 240, 0},
+		{"truncated", tkhd(identityMatrix, 640, 480)[8:60], 0, 0, 0},
+		{"empty", nil, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, rotation := parseTkhd(tt.body)
+			if width != tt.width || height != tt.height || rotation != tt.rotation {
+				t.Errorf("parseTkhd = %d×%d %d°, want %d×%d %d°", width, height, rotation, tt.width, tt.height, tt.rotation)
+			}
+		})
+	}
+}
+
+// sampleTables собирает stbl с длительностями кадров stts (пары число–длительность),
+// номерами ключевых кадров stss (nil — без stss) и числом кадров в stsz
+func sampleTables(samples uint32, stts []uint32, stss []uint32) []byte {
+	tables := [][]byte{
+		box("stts", u32s(0, uint32(len(stts)/2)), u32s(stts...)),
+		box("stsz", u32s(0, 1, samples)),
+	}
+	if stss != nil {
+		tables = append(tables, box("stss", u32s(0, uint32(len(stss))), u32s(stss...)))
+	}
+	return box("stbl", tables...)[8:]
+}
+
+func TestParseKeyframe(t *testing.T) {
+	const second = time.Second
+	tests := []struct {
+		name   string
+		stbl   []byte
+		at     time.Duration
+		want   time.Duration
+		wantOK bool
+	}{
+		// 300 кадров по 100 мс, ключевой кадр каждые 2 секунды
+		{"before keyframe", sampleTables(300, []uint32{300, 100}, []uint32{1, 21, 41}), 3 * second, 2 * second, true},
+		{"exactly on keyframe", sampleTables(300, []uint32{300, 100}, []uint32{1, 21, 41}), 4 * second, 4 * second, true},
+		{"at zero", sampleTables(300, []uint32{300, 100}, []uint32{1, 21}), 0, 0, true},
+		{"past last keyframe", sampleTables(300, []uint32{300, 100}, []uint32{1, 21}), 25 * second, 2 * second, true},
+		{"several stts entries", sampleTables(30, []uint32{10, 100, 20, 50}, []uint32{1, 11, 21}), 1500 * time.Millisecond, 1500 * time.Millisecond, true},
+		{"no stss means every sample", sampleTables(300, []uint32{300, 100}, nil), 1234 * time.Millisecond, 1200 * time.Millisecond, true},
+		{"no stss past the end", sampleTables(10, []uint32{10, 100}, nil), time.Hour, 900 * time.Millisecond, true},
+		{"unsorted stss", sampleTables(300, []uint32{300, 100}, []uint32{21, 1}), 3 * second, 0, false},
+		{"duplicate stss", sampleTables(300, []uint32{300, 100}, []uint32{1, 1}), 3 * second, 0, false},
+		{"zero sample number", sampleTables(300, []uint32{300, 100}, []uint32{0, 21}), 3 * second, 0, false},
+		{"keyframe beyond stsz count", sampleTables(10, []uint32{300, 100}, []uint32{1, 21}), 3 * second, 0, true},
+		{"no stsz", box("stbl", box("stts", u32s(0, 1, 10, 100)))[8:], second, 0, false},
+		{"no stts", box("stbl", box("stsz", u32s(0, 1, 10)))[8:], second, 0, false},
+		// Записанное в файле число кадров не определяет время разбора
+		{"huge count without stss", sampleTables(0xFFFFFFFF, []uint32{0xFFFFFFFF, 1}, nil), time.Hour, time.Hour, true},
+		{"huge count with zero delta", sampleTables(0xFFFFFFFF, []uint32{0xFFFFFFFF, 0, 0xFFFFFFFF, 0}, nil), time.Hour, 0, true},
+		{"huge count with sparse stss", sampleTables(0xFFFFFFFF, []uint32{0xFFFFFFFF, 1}, []uint32{1, 0xFFFFFFF0}), time.Hour, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseKeyframe(tt.stbl, 1000, tt.at)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseKeyframe = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
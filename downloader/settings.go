@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Settings — настраиваемые параметры скачивания
+type Settings struct {
+	UserAgent     string // User-Agent для запросов к сервисам скачивания
+	MaxFileSize   int64  // максимальный размер видео в байтах
+	FetchMetadata bool   // запрашивать автора и описание поста для подписи
+	FFmpegWorkers int    // сколько процессов ffmpeg может работать одновременно
+
+	SnapsaveURL          string // базовый адрес snapsave.app
+	TwitterDownloaderURL string // базовый адрес twitterdownloader.snapsave.app
+	SnaptikURL           string // базовый адрес snaptik.app
+	TikmateURL           string // базовый адрес tikmate.online
+	DDInstagramHost      string // домен-зеркало Instagram, подставляется вместо instagram.com
+	VXTwitterHost        string // домен-зеркало Twitter, подставляется вместо twitter.com
+}
+
+// DefaultSettings возвращает параметры скачивания по умолчанию
+func DefaultSettings() Settings {
+	return Settings{
+		UserAgent:            "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		MaxFileSize:          50 * 1024 * 1024,
+		FetchMetadata:        true,
+		FFmpegWorkers:        2,
+		SnapsaveURL:          "https://snapsave.app",
+		TwitterDownloaderURL: "https://twitterdownloader.snapsave.app",
+		SnaptikURL:           "https://snaptik.app",
+		TikmateURL:           "https://tikmate.online",
+		DDInstagramHost:      "ddinstagram.com",
+		VXTwitterHost:        "vxtwitter.com",
+	}
+}
+
+var (
+	settingsMu sync.RWMutex
+	current    = DefaultSettings()
+)
+
+// Configure заменяет параметры скачивания; безопасно вызывать во время работы
+func Configure(s Settings) {
+	s.SnapsaveURL = strings.TrimSuffix(s.SnapsaveURL, "/")
+	s.TwitterDownloaderURL = strings.TrimSuffix(s.TwitterDownloaderURL, "/")
+	s.SnaptikURL = strings.TrimSuffix(s.SnaptikURL, "/")
+	s.TikmateURL = strings.TrimSuffix(s.TikmateURL, "/")
+	setFFmpegWorkers(s.FFmpegWorkers)
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	current = s
+}
+
+func settings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return current
+}
+
+var (
+	disabledMu sync.RWMutex
+	disabled   = map[string]bool{}
+)
+
+// ProviderNames возвращает имена всех провайдеров в алфавитном порядке
+func ProviderNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, chain := range providers {
+		for _, p := range chain {
+			if !seen[p.name] {
+				seen[p.name] = true
+				names = append(names, p.name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDisabledProviders отключает ровно перечисленных провайдеров, включая остальные
+func SetDisabledProviders(names []string) error {
+	known := ProviderNames()
+	next := map[string]bool{}
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("неизвестный провайдер %q", name)
+		}
+		next[name] = true
+	}
+
+	disabledMu.Lock()
+	defer disabledMu.Unlock()
+	disabled = next
+	return nil
+}
+
+// ProviderEnabled сообщает, участвует ли провайдер в скачивании
+func ProviderEnabled(name string) bool {
+	disabledMu.RLock()
+	defer disabledMu.RUnlock()
+	return !disabled[name]
+}
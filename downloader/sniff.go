@@ -0,0 +1,100 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/png"
+	"io"
+	"os"
+)
+
+// mediaKind — формат файла, определённый по сигнатуре
+type mediaKind string
+
+const (
+	kindMP4  mediaKind = "mp4"
+	kindWebM mediaKind = "webm"
+	kindJPEG mediaKind = "jpeg"
+	kindPNG  mediaKind = "png"
+	kindGIF  mediaKind = "gif"
+)
+
+// sniffSize — сколько байт начала файла читается для определения формата
+const sniffSize = 4096
+
+// Идентификаторы элементов EBML (WebM, Matroska)
+var (
+	ebmlMagic   = []byte{0x1A, 0x45, 0xDF, 0xA3}
+	ebmlSegment = []byte{0x18, 0x53, 0x80, 0x67}
+)
+
+// sniffMedia определяет формат по первым байтам файла; пустая строка — формат не медиа
+func sniffMedia(head []byte) mediaKind {
+	switch {
+	case len(head) >= 8 && firstBoxTypes[string(head[4:8])]:
+		return kindMP4
+	case bytes.HasPrefix(head, ebmlMagic):
+		return kindWebM
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return kindJPEG
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return kindPNG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return kindGIF
+	}
+	return ""
+}
+
+// validateMedia проверяет, что скачанный файл — видео или изображение с целой
+// структурой, а не страница ошибки или капчи. Возвращает ErrNotMedia с описанием
+// того, что было скачано.
+func validateMedia(path string) (mediaKind, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	kind := sniffMedia(head)
+	switch kind {
+	case "":
+		return "", fmt.Errorf("%w: начало файла %q", ErrNotMedia, printablePrefix(head))
+	case kindMP4:
+		info, err := ProbeMP4(path)
+		if err != nil {
+			return kind, fmt.Errorf("%w: повреждённый MP4: %v", ErrNotMedia, err)
+		}
+		if info.VideoCodec == "" && info.AudioCodec == "" {
+			return kind, fmt.Errorf("%w: в MP4 нет видео- и звуковых дорожек", ErrNotMedia)
+		}
+	case kindWebM:
+		// За заголовком EBML с типом документа должен начинаться сегмент с данными
+		if !bytes.Contains(head, []byte("webm")) && !bytes.Contains(head, []byte("matroska")) {
+			return kind, fmt.Errorf("%w: неизвестный тип документа EBML", ErrNotMedia)
+		}
+		if !bytes.Contains(head, ebmlSegment) {
+			return kind, fmt.Errorf("%w: в WebM нет сегмента с данными", ErrNotMedia)
+		}
+	default:
+		if _, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(head), f)); err != nil {
+			return kind, fmt.Errorf("%w: повреждённое изображение %s: %v", ErrNotMedia, kind, err)
+		}
+	}
+	return kind, nil
+}
+
+// printablePrefix возвращает начало файла для лога: страницы ошибок обычно
+// можно узнать по первым символам HTML или JSON
+func printablePrefix(head []byte) string {
+	head = bytes.TrimSpace(head[:min(len(head), 64)])
+	return string(bytes.ToValidUTF8(head, []byte("?")))
+}
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want mediaKind
+	}{
+		{"mp4", ftyp, kindMP4},
+		{"mov with moov first", moov(), kindMP4},
+		{"webm", append(append([]byte{}, ebmlMagic...), "webm"...), kindWebM},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, kindJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), kindPNG},
+		{"gif", []byte("GIF89a"), kindGIF},
+		{"html error page", []byte("<!DOCTYPE html><html><head><title>403</title>"), ""},
+		{"json error", []byte(`{"error":"rate limited","status":429}`), ""},
+		{"empty", nil, ""},
+		{"short", []byte("GIF"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffMedia(tt.head); got != tt.want {
+				t.Errorf("sniffMedia = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMedia(t *testing.T) {
+	video := moov(trak(tkhd(identityMatrix, 640, 360), "vide", "avc1", 640, 360))
+	webm := append(append([]byte{}, ebmlMagic...), "\x42\x82\x84webm"...)
+
+	tests := []struct {
+		name     string
+		file     [][]byte
+		want     mediaKind
+		notMedia bool
+	}{
+		{name: "mp4", file: [][]byte{ftyp, video, mdat}, want: kindMP4},
+		{name: "webm", file: [][]byte{webm, ebmlSegment, make([]byte, 16)}, want: kindWebM},
+		{name: "png", file: [][]byte{pngImage(t)}, want: kindPNG},
+		{name: "html error page", file: [][]byte{[]byte("<html><body>Too many requests</body></html>")}, notMedia: true},
+		{name: "json error", file: [][]byte{[]byte(`{"status":"error","message":"video not found"}`)}, notMedia: true},
+		{name: "mp4 without moov", file: [][]byte{ftyp, mdat}, notMedia: true},
+		{name: "mp4 without tracks", file: [][]byte{ftyp, moov(), mdat}, notMedia: true},
+		{name: "webm without segment", file: [][]byte{webm}, notMedia: true},
+		{name: "ebml of unknown doctype", file: [][]byte{ebmlMagic, []byte("\x42\x82\x84xxxx"), ebmlSegment}, notMedia: true},
+		{name: "truncated png", file: [][]byte{pngImage(t)[:12]}, notMedia: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, err := validateMedia(writeFile(t, tt.file...))
+			if tt.notMedia {
+				if !errors.Is(err, ErrNotMedia) {
+					t.Errorf("validateMedia error = %v, want ErrNotMedia", err)
+				}
+				return
+			}
+			if err != nil || kind != tt.want {
+				t.Errorf("validateMedia = %q, %v, want %q", kind, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintablePrefix(t *testing.T) {
+	head := append([]byte("  <html>\xff"), bytes.Repeat([]byte("a"), 100)...)
+	got := printablePrefix(head)
+	if len(got) > 64 || got[:7] != "<html>?" {
+		t.Errorf("printablePrefix = %q", got)
+	}
+}
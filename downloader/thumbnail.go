@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"image"
+	_ "image/jpeg"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Требования Telegram к обложке видео: JPEG не больше 320×320 и 200 КБ
+const (
+	thumbnailSide     = 320
+	thumbnailMaxBytes = 200 * 1024
+)
+
+// thumbnailTimeout — сколько ждать скачивания превью и ffmpeg; без обложки видео всё равно отправится
+const thumbnailTimeout = 20 * time.Second
+
+// thumbnailScale уменьшает картинку до 320 пикселей по большей стороне, не увеличивая маленькие
+var thumbnailScale = fmt.Sprintf("scale=w='min(%[1]d,iw)':h='min(%[1]d,ih)':force_original_aspect_ratio=decrease", thumbnailSide)
+
+// downloadThumbnail скачивает превью, которое показывает сервис скачивания, рядом с видео.
+// Возвращает путь к файлу или пустую строку: без превью обложка строится из кадра видео.
+func downloadThumbnail(ctx context.Context, thumbnailURL, videoPath string) string {
+	if thumbnailURL == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, thumbnailTimeout)
+	defer cancel()
+	logger := logging.From(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", thumbnailURL, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", getUserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Debug("Не удалось скачать превью", "error", err)
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		logger.Debug("Превью не скачано", "status", resp.StatusCode, "content_type", resp.Header.Get("Content-Type"))
+		return ""
+	}
+
+	path := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".preview"
+	f, err := os.Create(path)
+	if err != nil {
+		return ""
+	}
+	// Превью больше нескольких мегабайт — не картинка для обложки
+	_, err = io.Copy(f, io.LimitReader(resp.Body, 5*1024*1024))
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		return ""
+	}
+	return path
+}
+
+// Thumbnail — шаг, который готовит обложку видео из превью сервиса, скачанного
+// провайдером, или из кадра видео. Без обложки видео всё равно отправится.
+func Thumbnail() Stage {
+	return Stage{Name: "thumbnail", Timeout: thumbnailTimeout, Optional: true, Run: func(ctx context.Context, job *Job) error {
+		job.Media.Thumbnail = prepareThumbnail(ctx, job.Media.Path, job.Media.Thumbnail)
+		return nil
+	}}
+}
+
+// prepareThumbnail готовит обложку видео в формате, который принимает Telegram:
+// уменьшает превью сервиса, а без него — берёт характерный кадр видео через ffmpeg.
+// Возвращает путь к JPEG или пустую строку, если обложку сделать не удалось.
+func prepareThumbnail(ctx context.Context, videoPath, preview string) string {
+	logger := logging.From(ctx)
+
+	path := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".jpg"
+	if preview != "" {
+		defer os.Remove(preview)
+
+		err := runFFmpeg(ctx, "-i", preview, "-vf", thumbnailScale, "-frames:v", "1", "-q:v", "4", path)
+		if err == nil && fitsThumbnail(path) {
+			return path
+		}
+		// Без ffmpeg подходящее превью используется как есть
+		if fitsThumbnail(preview) && os.Rename(preview, path) == nil {
+			return path
+		}
+		logger.Debug("Превью сервиса не подошло для обложки", "error", err)
+	}
+
+	// Фильтр thumbnail выбирает самый характерный кадр из первых 50, пропуская тёмные и смазанные
+	err := runFFmpeg(ctx, "-i", videoPath, "-vf", "thumbnail=50,"+thumbnailScale, "-frames:v", "1", "-q:v", "4", path)
+	if err == nil && fitsThumbnail(path) {
+		return path
+	}
+	os.Remove(path)
+	logger.Debug("Не удалось сделать обложку из кадра видео", "error", err)
+	return ""
+}
+
+// fitsThumbnail проверяет, что файл — JPEG не больше 320×320 и 200 КБ
+func fitsThumbnail(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > thumbnailMaxBytes {
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	config, format, err := image.DecodeConfig(f)
+	return err == nil && format == "jpeg" && config.Width <= thumbnailSide && config.Height <= thumbnailSide
+}
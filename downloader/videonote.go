@@ -0,0 +1,45 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"goland/VideoSaverBot/logging"
+	"time"
+)
+
+// Требования Telegram к видеосообщению: квадрат до 640 пикселей и не длиннее минуты.
+// Меньше 384 пикселей кружок выглядит размыто, поэтому маленькие видео увеличиваются.
+const (
+	VideoNoteMaxDuration = time.Minute
+	videoNoteMinSide     = 384
+	videoNoteMaxSide     = 640
+)
+
+// videoNoteFilter вырезает квадрат из центра кадра и приводит его сторону к 384–640 пикселям;
+// сторона округляется до чётной, как требует H.264
+var videoNoteFilter = fmt.Sprintf("crop='min(iw,ih)':'min(iw,ih)',scale='trunc(min(%d,max(%d,iw))/2)*2':ow",
+	videoNoteMaxSide, videoNoteMinSide)
+
+// VideoNote — шаг, который перекодирует видео в видеосообщение: квадрат из центра
+// кадра со стороной 384–640 пикселей, не длиннее минуты
+func VideoNote() Stage {
+	return Stage{Name: "video_note", Timeout: conversionTimeout, Run: convertToVideoNote}
+}
+
+func convertToVideoNote(ctx context.Context, job *Job) error {
+	logger := logging.From(ctx)
+	start := time.Now()
+	out := job.Output(".note.mp4")
+
+	err := encode(ctx, "-i", job.Media.Path, "-t", timestamp(VideoNoteMaxDuration), "-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", videoNoteFilter, "-c:v", "libx264", "-preset", "veryfast", "-crf", "26", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "96k", "-movflags", "+faststart", out)
+	if err != nil {
+		return err
+	}
+
+	job.Replace(out)
+	job.Media.Animation = false
+	logger.Info("Видео преобразовано в видеосообщение", "bytes", fileSize(out), "elapsed", time.Since(start))
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"goland/VideoSaverBot/downloader"
+	"strings"
+)
+
+// gifFileArg — аргумент /gif, по которому отправляется настоящий GIF, а не MP4
+const gifFileArg = "file"
+
+// gifConversion возвращает преобразование для /gif: MP4 без звука, который Telegram
+// показывает как GIF, или, с аргументом file, настоящий GIF, отправленный файлом
+func gifConversion(args string) conversion {
+	for _, arg := range strings.Fields(args) {
+		if strings.EqualFold(arg, gifFileArg) {
+			return conversion{name: "gif-file", stages: downloader.Pipeline{downloader.GIF()}, send: fileDocument}
+		}
+	}
+	return conversion{name: "gif", stages: downloader.Pipeline{downloader.Animation()}}
+}
@@ -0,0 +1,210 @@
+package i18n
+
+var en = map[string]string{
+	"lang.name": "English",
+
+	"command.start":       "Start using the bot",
+	"command.help":        "Show usage instructions",
+	"command.lang":        "Choose language",
+	"command.settings":    "Group settings",
+	"command.dl":          "Download links from the message you reply to",
+	"command.gif":         "Make a GIF from a video link",
+	"command.clip":        "Cut a part of a video: /clip <link> 0:10-0:25",
+	"command.note":        "Turn a video into a round video message",
+	"command.stats":       "Bot statistics",
+	"command.ban":         "Ban a user or chat",
+	"command.unban":       "Unban a user or chat",
+	"command.broadcast":   "Message all users",
+	"command.maintenance": "Maintenance mode: on|off",
+	"command.provider":    "Enable or disable a provider",
+	"command.deeplink":    "A t.me link that delivers a video right away",
+
+	"start": "Hi! I download videos from Instagram, Twitter (X), TikTok, Facebook and YouTube Shorts. " +
+		"Just send me a link to a post and I'll save the video for you.\n\n",
+	"start.group":   "Hi! I'm ready to download videos from Instagram, Twitter, TikTok, Facebook and YouTube Shorts. Just send me a link.",
+	"start.expired": "This link has expired or is invalid. Please send me the video link again.",
+	"help": "🔍 *How to use*:\n\n" +
+		"1. Find a video on Instagram, Twitter (X), TikTok, Facebook or YouTube Shorts\n" +
+		"2. Copy the link to the post/video\n" +
+		"3. Send me the link\n" +
+		"4. Wait for the download and get your video\n\n" +
+		"*Supported platforms*:\n" +
+		"• Instagram (posts and reels)\n" +
+		"• Twitter/X\n" +
+		"• TikTok\n" +
+		"• Facebook\n" +
+		"• YouTube Shorts (short videos only)\n\n" +
+		"*YouTube*: Only Shorts are supported (youtube.com/shorts/). Use third-party sites for long videos.\n\n" +
+		"*In group chats*: I only handle video links or messages that mention me (@{bot}). To download a link from someone else's message, reply to it with /dl or mention me. Group admins can configure me with /settings\n\n" +
+		"*In any chat*: type @{bot} followed by a link to send the video right into the conversation\n\n" +
+		"*GIF*: /gif <link> — the video without sound, playing in a loop; /gif <link> file — a real GIF file\n\n" +
+		"*Clip*: /clip <link> 1:05-1:20 or as a reply to a video — just the seconds you need\n\n" +
+		"*Round video*: /note <link> or as a reply to a video — a video message cropped from the center of the frame\n\n" +
+		"*Language*: /lang en or /lang ru",
+
+	"not_a_link": "Please send a link to an Instagram, Twitter, TikTok, Facebook or YouTube Shorts post with a video.",
+	"youtube.not_shorts": "I only support YouTube Shorts (short videos).\n\n" +
+		"Links should look like: youtube.com/shorts/VIDEO_ID\n\n" +
+		"To download regular YouTube videos, use third-party sites, for example:\n" +
+		"• savefrom.net\n" +
+		"• y2mate.com\n" +
+		"• 9xbuddy.com",
+	"maintenance": "The bot is under maintenance. Please try again later.",
+
+	"limit.quota":  "Daily download limit reached. It resets at %s UTC (in %s).",
+	"limit.rate":   "Too many requests. Try again in %s.",
+	"wait.seconds": "%d s",
+	"wait.minutes": "%d min",
+	"wait.hours":   "%d h %d min",
+
+	"dl.usage": "Reply with /dl to a message with a video link and I will download it and post it as a reply to that message.",
+	"gif.usage": "Send /gif with a video link or reply with /gif to a message with a link, and I will send the first 30 seconds without sound as a GIF. " +
+		"Add file to get a real GIF file (up to 15 seconds).",
+	"clip.usage": "Send /clip with a video link and a range, e.g. /clip <link> 1:05-1:20, or reply with /clip 1:05-1:20 to a video or a message with a link. " +
+		"Times are in seconds, m:s or h:m:s.",
+	"clip.out_of_range": "The range starts after the end of the video: it is %s long.",
+	"note.usage":        "Send /note with a video link or reply with /note to a video or a message with a link, and I will send the first minute as a round video message.",
+
+	"busy":           "Your downloads are still in progress, please wait...",
+	"too_many_links": "You can download at most %d links at once, the rest were skipped.",
+
+	"processing.instagram": "Processing Instagram link...",
+	"processing.twitter":   "Processing Twitter/X link...",
+	"processing.tiktok":    "Processing TikTok link...",
+	"processing.facebook":  "Processing Facebook link...",
+	"processing.youtube":   "Processing YouTube link...",
+	"processing.file":      "Processing the video...",
+	"queue.position":       "All slots are busy, please wait... (position in queue: %d)",
+
+	"error.download":         "Failed to download the video. Please try again later.",
+	"error.timeout":          "The download took too long. Please try again later.",
+	"error.private":          "The video is private and can't be downloaded.",
+	"error.age_restricted":   "The video is age-restricted and can't be downloaded.",
+	"error.geo_blocked":      "The video is not available in the server's region.",
+	"error.unavailable":      "The video is unavailable: it may have been deleted or made private.",
+	"error.too_large":        "The video is too large to send via Telegram.",
+	"error.auth_required":    "The service requires sign-in. Please try again later.",
+	"error.tool_unavailable": "Downloads from this platform are temporarily unavailable.",
+	"error.not_found":        "Couldn't find a video at this link. Make sure the post contains a video.",
+	"error.not_media":        "The service returned an error page instead of the video. Try again later.",
+	"error.conversion":       "Couldn't convert the video. Try another one.",
+	"error.disabled":         "Downloads from this platform are temporarily disabled.",
+	"error.too_long":         "The video is longer than this group allows.",
+	"error.file_too_big":     "I can only download videos up to 20 MB from Telegram. Send a link to the original instead.",
+	"error.send":             "Failed to send the video. Please try again.",
+	"error.unsupported":      "This platform is not supported.",
+	"error.storage":          "Couldn't save the video on the server. Please try again later.",
+	"error.service":          "The download service didn't respond or returned an error. Please try again later.",
+
+	"batch.progress": "Processing links: %d of %d done",
+	"batch.summary":  "Done: %d of %d.",
+	"batch.failed":   "Failed to download:",
+
+	"lang.current":    "Current language: %s.\n\nUsage: /lang en or /lang ru",
+	"lang.set":        "Language changed: %s.",
+	"lang.chat_admin": "Only group administrators can change the group language.",
+	"lang.unknown":    "Unknown language. Available: %s.",
+
+	"inline.usage": "Paste a video link after the bot name",
+	"inline.open":  "Download in the bot chat",
+
+	"deeplink.usage":  "Usage: /deeplink <video link>",
+	"deeplink.failed": "Could not create the link, please try again.",
+
+	"caption.source":    "Original",
+	"caption.requester": "Sent by: %s",
+
+	"settings.title": "⚙️ Group settings\n\n" +
+		"Tap an option to change it. Links to disabled (❌) platforms are ignored. " +
+		"With auto-download off, the bot only downloads links when it is mentioned. " +
+		"\"Links: bare only\" means the message must be a single link, \"anywhere\" also picks up links inside text, captions and forwarded messages.",
+	"settings.groups_only":   "Settings are only available in groups.",
+	"settings.chat_admin":    "Only group admins can change the group settings.",
+	"settings.on":            "on",
+	"settings.off":           "off",
+	"settings.auto":          "Auto-download: %s",
+	"settings.links":         "Links: %s",
+	"settings.links.bare":    "bare only",
+	"settings.links.any":     "anywhere",
+	"settings.caption":       "Caption: %s",
+	"settings.caption.none":  "none",
+	"settings.caption.link":  "platform and link",
+	"settings.caption.full":  "author and description",
+	"settings.reply":         "Reply to the message: %s",
+	"settings.delete":        "Delete the link message: %s",
+	"settings.audio":         "Audio only: %s",
+	"settings.duration":      "Max video length: %s",
+	"settings.duration.none": "no limit",
+	"settings.close":         "Close",
+
+	"admin.bad_id":                   "Invalid ID: %s",
+	"admin.ban.usage":                "Usage: %s <user_id|chat_id> or reply to the user's message",
+	"admin.ban.admin":                "Administrators cannot be banned.",
+	"admin.ban.user":                 "User %d is banned.",
+	"admin.ban.chat":                 "Chat %d is banned.",
+	"admin.unban.user":               "User %d is unbanned.",
+	"admin.unban.chat":               "Chat %d is unbanned.",
+	"admin.maintenance.enabled":      "Maintenance mode is on. Notice: %s",
+	"admin.maintenance.on":           "Maintenance mode is on.",
+	"admin.maintenance.off":          "Maintenance mode is off.",
+	"admin.maintenance.usage":        "Usage: /maintenance on [notice text] | off",
+	"admin.provider.list":            "Providers:",
+	"admin.provider.state.enabled":   "enabled",
+	"admin.provider.state.disabled":  "disabled",
+	"admin.provider.usage":           "Usage: /provider enable|disable <name>",
+	"admin.provider.unknown":         "Unknown provider %q. Available providers: %s",
+	"admin.provider.config_disabled": "Provider %s is disabled in the configuration (providers.disabled).",
+	"admin.provider.enabled":         "Provider %s is enabled.",
+	"admin.provider.disabled":        "Provider %s is disabled.",
+	"admin.broadcast.usage":          "Usage: /broadcast <text> or reply to the message to broadcast",
+	"admin.broadcast.running":        "A broadcast is already running.",
+	"admin.broadcast.started":        "Broadcast: 0 of %d",
+	"admin.broadcast.progress":       "Broadcast: %d of %d\nDelivered: %d\nFailed: %d\nBlocked the bot: %d",
+	"admin.broadcast.done":           "Broadcast finished.",
+
+	"stats.usage":           "Usage: /stats [today|providers|errors]",
+	"stats.title":           "<b>Statistics</b>\nUptime: %v\nQueued: %d, running: %d",
+	"stats.period":          "Period",
+	"stats.failures":        "Errors",
+	"stats.cached":          "Cached",
+	"stats.success":         "Rate",
+	"stats.users":           "Users",
+	"stats.chats":           "Chats",
+	"stats.today":           "Today",
+	"stats.days":            "%d days",
+	"stats.today.empty":     "No downloads today yet.",
+	"stats.today.title":     "<b>Today by platform</b>",
+	"stats.today.unique":    "Unique users: %d, chats: %d",
+	"stats.platform":        "Platform",
+	"stats.median":          "Median",
+	"stats.seconds":         "s",
+	"stats.total":           "Total",
+	"stats.providers.empty": "No providers were called in the last %d days.",
+	"stats.providers.title": "<b>Providers for %d days</b>",
+	"stats.provider":        "Provider",
+	"stats.errors.empty":    "No errors in the last %d days.",
+	"stats.errors.title":    "<b>Errors for %d days</b>",
+	"stats.reason":          "Reason",
+	"stats.count":           "Count",
+	"stats.share":           "Share",
+
+	"stats.reason.timeout":          "Timeout",
+	"stats.reason.private":          "Private video",
+	"stats.reason.age_restricted":   "Age restricted",
+	"stats.reason.geo_blocked":      "Geo-blocked",
+	"stats.reason.unavailable":      "Video unavailable",
+	"stats.reason.too_large":        "File too large",
+	"stats.reason.auth_required":    "Login required",
+	"stats.reason.tool_unavailable": "Download tool missing",
+	"stats.reason.not_found":        "Video not found",
+	"stats.reason.not_media":        "Not a video",
+	"stats.reason.conversion":       "Conversion failed",
+	"stats.reason.disabled":         "Providers disabled",
+	"stats.reason.unsupported":      "Unsupported platform",
+	"stats.reason.storage":          "File write error",
+	"stats.reason.service":          "Download service error",
+	"stats.reason.too_long":         "Longer than group limit",
+	"stats.reason.file_too_big":     "Telegram file over 20 MB",
+	"stats.reason.send":             "Send failed",
+	"stats.reason.other":            "Other errors",
+}
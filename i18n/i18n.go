@@ -0,0 +1,114 @@
+// Package i18n содержит каталог сообщений бота на поддерживаемых языках
+// и передаёт язык пользователя через context.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Lang — код языка в формате Telegram (language_code без региона)
+type Lang string
+
+const (
+	RU Lang = "ru"
+	EN Lang = "en"
+
+	// Default используется, если язык пользователя неизвестен
+	Default = RU
+)
+
+// Supported — поддерживаемые языки в порядке отображения
+var Supported = []Lang{RU, EN}
+
+var catalogs = map[Lang]map[string]string{
+	RU: ru,
+	EN: en,
+}
+
+var (
+	overridesMu sync.RWMutex
+	overrides   map[Lang]map[string]string
+)
+
+// Parse выбирает язык по language_code Telegram ("en-US" → en).
+// Без кода возвращается Default, для неподдерживаемых языков — английский.
+func Parse(code string) Lang {
+	code, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(code)), "-")
+	if code == "" {
+		return Default
+	}
+	if lang, ok := Lookup(code); ok {
+		return lang
+	}
+	return EN
+}
+
+// Lookup возвращает язык, если он поддерживается
+func Lookup(code string) (Lang, bool) {
+	lang := Lang(strings.ToLower(code))
+	_, ok := catalogs[lang]
+	return lang, ok
+}
+
+// Has сообщает, есть ли ключ в каталоге
+func Has(key string) bool {
+	_, ok := catalogs[Default][key]
+	return ok
+}
+
+// SetOverrides заменяет тексты каталога, например из файла конфигурации
+func SetOverrides(o map[Lang]map[string]string) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	overrides = o
+}
+
+// T возвращает сообщение по ключу; при наличии args текст форматируется как в fmt.Sprintf.
+// Если перевода нет, используется текст на языке по умолчанию, затем сам ключ.
+func (l Lang) T(key string, args ...any) string {
+	text, ok := l.lookup(key)
+	if !ok {
+		text, ok = Default.lookup(key)
+	}
+	if !ok {
+		text = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+func (l Lang) lookup(key string) (string, bool) {
+	overridesMu.RLock()
+	text, ok := overrides[l][key]
+	overridesMu.RUnlock()
+	if ok {
+		return text, true
+	}
+	text, ok = catalogs[l][key]
+	return text, ok
+}
+
+// Name возвращает название языка на нём самом
+func (l Lang) Name() string {
+	return l.T("lang.name")
+}
+
+type langKey struct{}
+
+// With возвращает контекст с языком пользователя
+func With(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// From возвращает язык из контекста или Default
+func From(ctx context.Context) Lang {
+	if lang, ok := ctx.Value(langKey{}).(Lang); ok {
+		return lang
+	}
+	return Default
+}
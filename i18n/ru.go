@@ -0,0 +1,210 @@
+package i18n
+
+var ru = map[string]string{
+	"lang.name": "Русский",
+
+	"command.start":       "Начать работу с ботом",
+	"command.help":        "Показать инструкцию по использованию",
+	"command.lang":        "Выбрать язык",
+	"command.settings":    "Настройки группы",
+	"command.dl":          "Скачать ссылки из сообщения, на которое вы отвечаете",
+	"command.gif":         "Сделать GIF из видео по ссылке",
+	"command.clip":        "Вырезать фрагмент видео: /clip <ссылка> 0:10-0:25",
+	"command.note":        "Сделать видеосообщение (кружок) из видео",
+	"command.stats":       "Статистика бота",
+	"command.ban":         "Заблокировать пользователя или чат",
+	"command.unban":       "Разблокировать пользователя или чат",
+	"command.broadcast":   "Рассылка всем пользователям",
+	"command.maintenance": "Режим обслуживания: on|off",
+	"command.provider":    "Включить или отключить провайдера",
+	"command.deeplink":    "Ссылка t.me, которая сразу присылает видео",
+
+	"start": "Привет! Я бот для скачивания видео из Instagram, Twitter (X), TikTok, Facebook и YouTube Shorts. " +
+		"Просто отправь мне ссылку на пост, и я сохраню для тебя видео.\n\n",
+	"start.group":   "Привет! Я готов скачивать видео из Instagram, Twitter, TikTok, Facebook и YouTube Shorts. Просто отправь мне ссылку.",
+	"start.expired": "Ссылка устарела или повреждена. Отправьте мне ссылку на видео ещё раз.",
+	"help": "🔍 *Как использовать*:\n\n" +
+		"1. Найдите видео в Instagram, Twitter (X), TikTok, Facebook или YouTube Shorts\n" +
+		"2. Скопируйте ссылку на пост/видео\n" +
+		"3. Отправьте мне эту ссылку\n" +
+		"4. Дождитесь загрузки и получите видео\n\n" +
+		"*Поддерживаемые платформы*:\n" +
+		"• Instagram (посты и reels)\n" +
+		"• Twitter/X\n" +
+		"• TikTok\n" +
+		"• Facebook\n" +
+		"• YouTube Shorts (только короткие видео)\n\n" +
+		"*YouTube*: Поддерживаю только Shorts (youtube.com/shorts/). Для длинных видео используйте сторонние сайты.\n\n" +
+		"*В групповых чатах*: Я обрабатываю только ссылки на видео или сообщения, в которых меня упоминают (@{bot}). Чтобы скачать ссылку из чужого сообщения, ответьте на него командой /dl или упомяните меня. Администраторы группы настраивают моё поведение командой /settings\n\n" +
+		"*В любом чате*: напишите @{bot} и ссылку — видео можно отправить прямо в переписку\n\n" +
+		"*GIF*: /gif <ссылка> — видео без звука, которое проигрывается по кругу; /gif <ссылка> file — настоящий GIF-файл\n\n" +
+		"*Фрагмент*: /clip <ссылка> 1:05-1:20 или ответом на видео — только нужные секунды\n\n" +
+		"*Кружок*: /note <ссылка> или ответом на видео — видеосообщение из центра кадра\n\n" +
+		"*Язык*: /lang ru или /lang en",
+
+	"not_a_link": "Пожалуйста, отправьте ссылку на пост из Instagram, Twitter, TikTok, Facebook или YouTube Shorts, содержащий видео.",
+	"youtube.not_shorts": "Я поддерживаю только YouTube Shorts (короткие видео).\n\n" +
+		"Ссылки должны быть вида: youtube.com/shorts/VIDEO_ID\n\n" +
+		"Для скачивания обычных YouTube видео используйте сторонние сайты, например:\n" +
+		"• savefrom.net\n" +
+		"• y2mate.com\n" +
+		"• 9xbuddy.com",
+	"maintenance": "Бот на техническом обслуживании. Попробуйте позже.",
+
+	"limit.quota":  "Дневной лимит загрузок исчерпан. Лимит сбросится в %s UTC (через %s).",
+	"limit.rate":   "Слишком много запросов. Попробуйте снова через %s.",
+	"wait.seconds": "%d с",
+	"wait.minutes": "%d мин",
+	"wait.hours":   "%d ч %d мин",
+
+	"dl.usage": "Ответьте командой /dl на сообщение со ссылкой на видео — я скачаю его и пришлю ответом на это сообщение.",
+	"gif.usage": "Отправьте /gif и ссылку на видео или ответьте командой /gif на сообщение со ссылкой — я пришлю первые 30 секунд без звука как GIF. " +
+		"С аргументом file — настоящий GIF-файл (до 15 секунд).",
+	"clip.usage": "Отправьте /clip, ссылку на видео и интервал, например /clip <ссылка> 1:05-1:20, или ответьте командой /clip 1:05-1:20 на видео или сообщение со ссылкой. " +
+		"Время указывается в секундах, м:с или ч:м:с.",
+	"clip.out_of_range": "Интервал начинается после конца видео: его длина %s.",
+	"note.usage":        "Отправьте /note и ссылку на видео или ответьте командой /note на видео или сообщение со ссылкой — я пришлю первую минуту кружком.",
+
+	"busy":           "Ваши загрузки ещё обрабатываются, подождите...",
+	"too_many_links": "Одновременно можно скачивать не более %d ссылок, лишние ссылки пропущены.",
+
+	"processing.instagram": "Обрабатываю Instagram ссылку...",
+	"processing.twitter":   "Обрабатываю Twitter/X ссылку...",
+	"processing.tiktok":    "Обрабатываю TikTok ссылку...",
+	"processing.facebook":  "Обрабатываю Facebook ссылку...",
+	"processing.youtube":   "Обрабатываю YouTube ссылку...",
+	"processing.file":      "Обрабатываю видео...",
+	"queue.position":       "Все слоты заняты, ожидайте... (позиция в очереди: %d)",
+
+	"error.download":         "Не удалось скачать видео. Попробуйте позже.",
+	"error.timeout":          "Скачивание заняло слишком много времени. Попробуйте позже.",
+	"error.private":          "Видео приватное, скачать его нельзя.",
+	"error.age_restricted":   "Видео имеет возрастные ограничения, скачать его нельзя.",
+	"error.geo_blocked":      "Видео недоступно в регионе сервера.",
+	"error.unavailable":      "Видео недоступно: возможно, оно удалено или приватное.",
+	"error.too_large":        "Видео слишком большое для отправки через Telegram.",
+	"error.auth_required":    "Сервис требует авторизацию. Попробуйте позже.",
+	"error.tool_unavailable": "Скачивание с этой платформы временно недоступно.",
+	"error.not_found":        "Не удалось найти видео по ссылке. Проверьте, что в посте есть видео.",
+	"error.not_media":        "Сервис вернул вместо видео страницу ошибки. Попробуйте позже.",
+	"error.conversion":       "Не удалось преобразовать видео. Попробуйте другое видео.",
+	"error.disabled":         "Скачивание с этой платформы временно отключено.",
+	"error.too_long":         "Видео длиннее, чем разрешено в этой группе.",
+	"error.file_too_big":     "Бот может скачать из Telegram видео не больше 20 МБ. Пришлите ссылку на оригинал.",
+	"error.send":             "Не удалось отправить видео. Попробуйте еще раз.",
+	"error.unsupported":      "Эта платформа не поддерживается.",
+	"error.storage":          "Не удалось сохранить видео на сервере. Попробуйте позже.",
+	"error.service":          "Сервис скачивания не ответил или вернул ошибку. Попробуйте позже.",
+
+	"batch.progress": "Обрабатываю ссылки: готово %d из %d",
+	"batch.summary":  "Готово: %d из %d.",
+	"batch.failed":   "Не удалось скачать:",
+
+	"lang.current":    "Текущий язык: %s.\n\nИспользование: /lang ru или /lang en",
+	"lang.set":        "Язык изменён: %s.",
+	"lang.chat_admin": "Язык группы могут менять только её администраторы.",
+	"lang.unknown":    "Неизвестный язык. Доступны: %s.",
+
+	"inline.usage": "Вставьте ссылку на видео после имени бота",
+	"inline.open":  "Скачать в чате с ботом",
+
+	"deeplink.usage":  "Использование: /deeplink <ссылка на видео>",
+	"deeplink.failed": "Не удалось создать ссылку, попробуйте ещё раз.",
+
+	"caption.source":    "Оригинал",
+	"caption.requester": "Прислал(а): %s",
+
+	"settings.title": "⚙️ Настройки группы\n\n" +
+		"Нажмите на параметр, чтобы изменить его. Ссылки на отключённые (❌) платформы бот игнорирует. " +
+		"Без автоскачивания бот скачивает ссылки, только если его упомянули. " +
+		"«Ссылки: только отдельные» — сообщение должно состоять из одной ссылки, «в любом тексте» — ссылка может быть внутри текста, подписи или пересланного сообщения.",
+	"settings.groups_only":   "Настройки доступны только в группах.",
+	"settings.chat_admin":    "Настройки группы могут менять только её администраторы.",
+	"settings.on":            "вкл",
+	"settings.off":           "выкл",
+	"settings.auto":          "Автоскачивание: %s",
+	"settings.links":         "Ссылки: %s",
+	"settings.links.bare":    "только отдельные",
+	"settings.links.any":     "в любом тексте",
+	"settings.caption":       "Подпись: %s",
+	"settings.caption.none":  "нет",
+	"settings.caption.link":  "платформа и ссылка",
+	"settings.caption.full":  "автор и описание",
+	"settings.reply":         "Отвечать на сообщение: %s",
+	"settings.delete":        "Удалять сообщение со ссылкой: %s",
+	"settings.audio":         "Только аудио: %s",
+	"settings.duration":      "Макс. длина видео: %s",
+	"settings.duration.none": "без ограничений",
+	"settings.close":         "Закрыть",
+
+	"admin.bad_id":                   "Некорректный ID: %s",
+	"admin.ban.usage":                "Использование: %s <user_id|chat_id> или ответ на сообщение пользователя",
+	"admin.ban.admin":                "Нельзя заблокировать администратора.",
+	"admin.ban.user":                 "Пользователь %d заблокирован.",
+	"admin.ban.chat":                 "Чат %d заблокирован.",
+	"admin.unban.user":               "Пользователь %d разблокирован.",
+	"admin.unban.chat":               "Чат %d разблокирован.",
+	"admin.maintenance.enabled":      "Режим обслуживания включён. Уведомление: %s",
+	"admin.maintenance.on":           "Режим обслуживания включён.",
+	"admin.maintenance.off":          "Режим обслуживания выключен.",
+	"admin.maintenance.usage":        "Использование: /maintenance on [текст уведомления] | off",
+	"admin.provider.list":            "Провайдеры:",
+	"admin.provider.state.enabled":   "включён",
+	"admin.provider.state.disabled":  "отключён",
+	"admin.provider.usage":           "Использование: /provider enable|disable <имя>",
+	"admin.provider.unknown":         "Неизвестный провайдер %q. Доступные провайдеры: %s",
+	"admin.provider.config_disabled": "Провайдер %s отключён в конфигурации (providers.disabled).",
+	"admin.provider.enabled":         "Провайдер %s включён.",
+	"admin.provider.disabled":        "Провайдер %s отключён.",
+	"admin.broadcast.usage":          "Использование: /broadcast <текст> или ответ на сообщение для рассылки",
+	"admin.broadcast.running":        "Рассылка уже выполняется.",
+	"admin.broadcast.started":        "Рассылка: 0 из %d",
+	"admin.broadcast.progress":       "Рассылка: %d из %d\nДоставлено: %d\nОшибок: %d\nЗаблокировали бота: %d",
+	"admin.broadcast.done":           "Рассылка завершена.",
+
+	"stats.usage":           "Использование: /stats [today|providers|errors]",
+	"stats.title":           "<b>Статистика</b>\nВремя работы: %v\nВ очереди: %d, активных: %d",
+	"stats.period":          "Период",
+	"stats.failures":        "Ошибки",
+	"stats.cached":          "Кэш",
+	"stats.success":         "Успех",
+	"stats.users":           "Польз",
+	"stats.chats":           "Чаты",
+	"stats.today":           "Сегодня",
+	"stats.days":            "%d дней",
+	"stats.today.empty":     "Сегодня загрузок ещё не было.",
+	"stats.today.title":     "<b>Сегодня по платформам</b>",
+	"stats.today.unique":    "Уникальных пользователей: %d, чатов: %d",
+	"stats.platform":        "Платформа",
+	"stats.median":          "Медиана",
+	"stats.seconds":         "с",
+	"stats.total":           "Всего",
+	"stats.providers.empty": "За последние %d дней провайдеры не вызывались.",
+	"stats.providers.title": "<b>Провайдеры за %d дней</b>",
+	"stats.provider":        "Провайдер",
+	"stats.errors.empty":    "За последние %d дней ошибок не было.",
+	"stats.errors.title":    "<b>Ошибки за %d дней</b>",
+	"stats.reason":          "Причина",
+	"stats.count":           "Кол-во",
+	"stats.share":           "Доля",
+
+	"stats.reason.timeout":          "Таймаут",
+	"stats.reason.private":          "Приватное видео",
+	"stats.reason.age_restricted":   "Возрастное ограничение",
+	"stats.reason.geo_blocked":      "Региональная блокировка",
+	"stats.reason.unavailable":      "Видео недоступно",
+	"stats.reason.too_large":        "Слишком большой файл",
+	"stats.reason.auth_required":    "Требуется авторизация",
+	"stats.reason.tool_unavailable": "Нет утилиты скачивания",
+	"stats.reason.not_found":        "Видео не найдено",
+	"stats.reason.not_media":        "Скачано не видео",
+	"stats.reason.conversion":       "Ошибка преобразования",
+	"stats.reason.disabled":         "Провайдеры отключены",
+	"stats.reason.unsupported":      "Платформа не поддерживается",
+	"stats.reason.storage":          "Ошибка записи файла",
+	"stats.reason.service":          "Ошибка сервиса скачивания",
+	"stats.reason.too_long":         "Видео длиннее лимита группы",
+	"stats.reason.file_too_big":     "Файл Telegram больше 20 МБ",
+	"stats.reason.send":             "Ошибка отправки",
+	"stats.reason.other":            "Прочие ошибки",
+}
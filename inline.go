@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// inlineJobStore отслеживает скачивания для inline-ответов: повторные запросы той же
+// ссылки и переход в личный чат по кнопке присоединяются к уже идущему скачиванию
+type inlineJobStore struct {
+	mu      sync.Mutex
+	pending map[string]chan struct{}
+}
+
+var inlineJobs = &inlineJobStore{pending: make(map[string]chan struct{})}
+
+// download запускает фоновое скачивание ссылки для inline-ответа или присоединяется
+// к уже идущему. Возвращённый канал закрывается, когда скачивание завершилось.
+func (s *inlineJobStore) download(ctx context.Context, bot *tgbotapi.BotAPI, userID int64, link string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if done, ok := s.pending[link]; ok {
+		return done
+	}
+	done := make(chan struct{})
+	s.pending[link] = done
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.pending, link)
+			s.mu.Unlock()
+			close(done)
+		}()
+		downloadForInline(ctx, bot, userID, link)
+	}()
+	return done
+}
+
+// wait ждёт завершения фонового скачивания ссылки, если оно идёт, чтобы
+// личный чат получил видео из кэша, а не скачивал его второй раз
+func (s *inlineJobStore) wait(ctx context.Context, link string) {
+	s.mu.Lock()
+	done, ok := s.pending[link]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(currentConfig().Downloads.Timeout):
+		logging.From(ctx).Warn("Фоновое скачивание для inline-ответа не завершилось вовремя")
+	}
+}
+
+// inlineRetryCacheTime — сколько секунд Telegram кэширует ответ с кнопкой перехода
+// в личный чат, пока видео ещё скачивается
+const inlineRetryCacheTime = 1
+
+// handleInlineQuery отвечает на запрос @bot <ссылка>: видео из кэша или скачанное
+// за inline.wait. Иначе предлагает кнопку перехода в личный чат, где загрузка завершится.
+func handleInlineQuery(bot *tgbotapi.BotAPI, query *tgbotapi.InlineQuery) {
+	userID := query.From.ID
+	lang := languages.lang(&tgbotapi.Chat{ID: userID, Type: "private"}, query.From)
+	ctx := logging.With(context.Background(), "job", logging.NewJobID(), "user", userID, "inline", true)
+	ctx = i18n.With(ctx, lang)
+	logger := logging.From(ctx)
+	logger.Debug("Получен inline-запрос", "username", query.From.UserName, "query", query.Query)
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       []interface{}{},
+		IsPersonal:    true,
+	}
+
+	if !isAdmin(userID) && botAdmin.isBanned(userID, userID) {
+		logger.Debug("Inline-запрос от заблокированного пользователя проигнорирован")
+		answerInlineQuery(ctx, bot, answer)
+		return
+	}
+
+	link := inlineQueryLink(query.Query)
+	if link == "" {
+		answer.SwitchPMText = lang.T("inline.usage")
+		answer.SwitchPMParameter = startInline
+		answerInlineQuery(ctx, bot, answer)
+		return
+	}
+	ctx = logging.With(ctx, "link", link)
+
+	if cached, ok := inlineVideo(ctx, bot, userID, link); ok {
+		answer.Results = append(answer.Results, inlineResult(lang, link, cached))
+	} else {
+		// tgbotapi не отправляет нулевой cache_time, и Telegram кэширует ответ на 300 секунд;
+		// с коротким временем повторный запрос после фонового скачивания получит видео из кэша
+		answer.CacheTime = inlineRetryCacheTime
+		answer.SwitchPMText = lang.T("inline.open")
+		payload, err := deepLinkPayload(link)
+		if err != nil {
+			logging.From(ctx).Error("Не удалось создать payload deep link", "error", err)
+			payload = startInline
+		}
+		answer.SwitchPMParameter = payload
+	}
+	answerInlineQuery(ctx, bot, answer)
+}
+
+func answerInlineQuery(ctx context.Context, bot *tgbotapi.BotAPI, answer tgbotapi.InlineConfig) {
+	if _, err := bot.Request(answer); err != nil {
+		logging.From(ctx).Warn("Не удалось ответить на inline-запрос", "error", err)
+	}
+}
+
+// inlineQueryLink возвращает первую поддерживаемую ссылку из текста inline-запроса
+func inlineQueryLink(text string) string {
+	for _, word := range strings.Fields(text) {
+		if link, ok := matchLink(strings.Trim(word, linkPunctuation)); ok {
+			return link
+		}
+	}
+	return ""
+}
+
+// inlineVideo возвращает file_id видео для inline-ответа: из кэша или скачав
+// его и загрузив в служебный чат inline.storage_chat, если это успевает за inline.wait
+func inlineVideo(ctx context.Context, bot *tgbotapi.BotAPI, userID int64, link string) (cacheEntry, bool) {
+	if cached, ok := videoCache.get(link); ok {
+		botStats.recordCached(linkPlatform(link), userID, userID)
+		return cached, true
+	}
+
+	cfg := currentConfig().Inline
+	if cfg.StorageChat == 0 {
+		return cacheEntry{}, false
+	}
+
+	select {
+	case <-inlineJobs.download(ctx, bot, userID, link):
+		return videoCache.get(link)
+	case <-time.After(cfg.Wait):
+		logging.From(ctx).Debug("Видео не успело скачаться для inline-ответа")
+		return cacheEntry{}, false
+	}
+}
+
+// downloadForInline скачивает видео и загружает его в служебный чат, чтобы получить
+// file_id для inline-ответа. Ограничения проверяются как для личного чата; при отказе
+// пользователь узнает причину после перехода в личный чат.
+func downloadForInline(ctx context.Context, bot *tgbotapi.BotAPI, userID int64, link string) {
+	logger := logging.From(ctx)
+
+	if on, _ := botAdmin.maintenance(); on && !isAdmin(userID) {
+		return
+	}
+	if err := rateLimiter.allow(userID, userID, 1); err != nil {
+		return
+	}
+	if reserveUserJobs(userID, 1) == 0 {
+		return
+	}
+	defer releaseUserJobs(userID, 1)
+
+	platform := linkPlatform(link)
+	if err := acquireSlot(ctx, userID, userID, nil); err != nil {
+		logger.Info("Задача снята с очереди", "reason", err)
+		return
+	}
+	start := time.Now()
+	media, err := downloadLink(ctx, link, userID)
+	downloadScheduler.release()
+	latency := time.Since(start)
+
+	storage := delivery{chatID: currentConfig().Inline.StorageChat, lang: i18n.From(ctx), settings: defaultChatSettings()}
+	storage.settings.Reply = false
+	if err == nil {
+		err = storage.prepare(ctx, media)
+	}
+	botStats.recordJob(platform, userID, userID, latency, err)
+	if err != nil {
+		logger.Error("Ошибка скачивания для inline-ответа", "error", err)
+		return
+	}
+	defer media.Remove()
+	recordDownload(userID, media.Path)
+	fileID, err := uploadMedia(ctx, bot, storage, media, nil)
+	if err != nil {
+		logger.Error("Не удалось загрузить видео в служебный чат", "error", err)
+		return
+	}
+	videoCache.put(link, fileID, storage.fileKind(media), media.Meta)
+}
+
+// inlineResult строит результат inline-запроса с видео или анимацией по file_id и подписью
+// в стиле из конфигурации; автора запроса в подписи нет — его видно и так
+func inlineResult(lang i18n.Lang, link string, cached cacheEntry) interface{} {
+	platform := linkPlatform(link)
+	title := platformTitles[platform]
+	if cached.meta.Author != "" {
+		title += " · " + cached.meta.Author
+	}
+
+	cfg := currentConfig().Captions
+	caption := buildCaption(lang, cfg.Style, cfg.Format, cfg.DescriptionLength, link, platform, cached.meta, "")
+	parseMode := ""
+	if caption != "" {
+		parseMode = parseModes[cfg.Format]
+	}
+
+	if cached.kind == fileAnimation {
+		result := tgbotapi.NewInlineQueryResultCachedMPEG4GIF("animation", cached.fileID)
+		result.Title, result.Caption, result.ParseMode = title, caption, parseMode
+		return result
+	}
+	result := tgbotapi.NewInlineQueryResultCachedVideo("video", cached.fileID, title)
+	result.Description = truncateText(cached.meta.Description, 100)
+	result.Caption, result.ParseMode = caption, parseMode
+	return result
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"log/slog"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const languagesStateFile = "languages.json"
+
+// languageStore хранит язык, выбранный командой /lang, для пользователей и групп
+type languageStore struct {
+	mu    sync.Mutex
+	state languageState
+}
+
+type languageState struct {
+	Users map[int64]i18n.Lang `json:"users"`
+	Chats map[int64]i18n.Lang `json:"chats"`
+}
+
+func newLanguageStore() *languageStore {
+	s := &languageStore{}
+	if err := loadJSON(languagesStateFile, &s.state); err != nil {
+		slog.Error("Не удалось загрузить выбранные языки", "error", err)
+	}
+	if s.state.Users == nil {
+		s.state.Users = map[int64]i18n.Lang{}
+	}
+	if s.state.Chats == nil {
+		s.state.Chats = map[int64]i18n.Lang{}
+	}
+	return s
+}
+
+// messageLang выбирает язык ответа на сообщение
+func (s *languageStore) messageLang(message *tgbotapi.Message) i18n.Lang {
+	return s.lang(message.Chat, message.From)
+}
+
+// lang выбирает язык ответа: язык группы, затем язык, выбранный
+// пользователем, затем language_code из профиля Telegram
+func (s *languageStore) lang(chat *tgbotapi.Chat, user *tgbotapi.User) i18n.Lang {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lang, ok := s.state.Chats[chat.ID]; ok && !chat.IsPrivate() {
+		return lang
+	}
+	if user == nil {
+		return i18n.Default
+	}
+	if lang, ok := s.state.Users[user.ID]; ok {
+		return lang
+	}
+	return i18n.Parse(user.LanguageCode)
+}
+
+// setUser запоминает язык пользователя
+func (s *languageStore) setUser(userID int64, lang i18n.Lang) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Users[userID] = lang
+	s.saveLocked()
+}
+
+// setChat запоминает язык группы
+func (s *languageStore) setChat(chatID int64, lang i18n.Lang) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Chats[chatID] = lang
+	s.saveLocked()
+}
+
+func (s *languageStore) saveLocked() {
+	if err := saveJSON(languagesStateFile, &s.state); err != nil {
+		slog.Error("Не удалось сохранить выбранные языки", "error", err)
+	}
+}
+
+// handleLangCommand показывает или меняет язык. В личном чате язык
+// запоминается для пользователя, в группе — для всей группы.
+func handleLangCommand(ctx context.Context, bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	lang := i18n.From(ctx)
+
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("lang.current", lang.Name())))
+		return
+	}
+
+	next, ok := i18n.Lookup(arg)
+	if !ok {
+		names := make([]string, 0, len(i18n.Supported))
+		for _, l := range i18n.Supported {
+			names = append(names, string(l))
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("lang.unknown", strings.Join(names, ", "))))
+		return
+	}
+
+	if message.Chat.IsPrivate() {
+		languages.setUser(message.From.ID, next)
+	} else {
+		if !isAdmin(message.From.ID) && !isChatAdmin(ctx, bot, chatID, message.From.ID) {
+			bot.Send(tgbotapi.NewMessage(chatID, lang.T("lang.chat_admin")))
+			return
+		}
+		languages.setChat(chatID, next)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, next.T("lang.set", next.Name())))
+}
+
+// isChatAdmin проверяет через getChatMember, является ли пользователь администратором чата
+func isChatAdmin(ctx context.Context, bot *tgbotapi.BotAPI, chatID, userID int64) bool {
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		logging.From(ctx).Warn("Не удалось получить права участника чата", "error", err)
+		return false
+	}
+	return member.IsAdministrator() || member.IsCreator()
+}
+
+// errorText переводит ошибку скачивания в понятное пользователю сообщение.
+// Текст ошибки не показывается: он на русском и может содержать внутренние подробности.
+func errorText(lang i18n.Lang, err error) string {
+	var rangeErr *clipRangeError
+	if errors.As(err, &rangeErr) {
+		return clipMessage(lang, rangeErr)
+	}
+
+	reason := classifyError(err)
+	if reason == "other" {
+		return lang.T("error.download")
+	}
+	return lang.T("error." + reason)
+}
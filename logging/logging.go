@@ -0,0 +1,59 @@
+// Package logging настраивает структурированное логирование (log/slog) и
+// передаёт логгер задачи через context, чтобы все строки одной загрузки
+// можно было связать по job ID.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type loggerKey struct{}
+
+// Setup создаёт логгер с заданным уровнем (debug, info, warn, error) и форматом
+// (текст или JSON) и делает его логгером по умолчанию, в том числе для пакета log
+func Setup(w io.Writer, level string, json bool) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return nil, fmt.Errorf("неизвестный уровень логирования %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	if json {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// With возвращает контекст с логгером, дополненным атрибутами args
+func With(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey{}, From(ctx).With(args...))
+}
+
+// From возвращает логгер из контекста или логгер по умолчанию
+func From(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// NewJobID генерирует короткий идентификатор для связывания строк лога одной задачи
+func NewJobID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
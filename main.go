@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
 	"io"
 	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -16,12 +20,13 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf16"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -33,69 +38,117 @@ var (
 	facebookRegex  = regexp.MustCompile(`^https?://(?:www\.|web\.|m\.)?facebook\.com/(?:watch\?v=[0-9]+|watch/\?v=[0-9]+|reel/[0-9]+|[a-zA-Z0-9.\-_]+/(?:videos|posts)/[0-9]+|[0-9]+/(?:videos|posts)/[0-9]+|share/(?:v|r)/[a-zA-Z0-9]+)(?:[^/?#&]+.*)?$|^https://fb\.watch/[a-zA-Z0-9]+$`)
 	youtubeRegex   = regexp.MustCompile(`^(?:https?://)?(?:www\.)?youtube\.com/shorts/([a-zA-Z0-9_-]{11})(?:\S+)?$`)
 
-	_userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36"
+	downloadScheduler *scheduler
+	rateLimiter       *limiter
+	botStats          *statsStore
+	botAdmin          *adminStore
+	languages         *languageStore
+	groupSettings     *chatSettingsStore
 
-	downloadSemaphore chan struct{}
+	// shutdownCtx отменяется сигналом завершения: задачи в очереди планировщика
+	// снимаются сразу, начатые загрузки успевают завершиться
+	shutdownCtx = context.Background()
 
-	activeUsers  sync.Map
-	queuedCount  int64
-	runningCount int64
-	statTotal    int64
-	statErrors   int64
-	statStart    = time.Now()
-	adminID      int64
+	statStart = time.Now()
 )
 
 func main() {
-	botTokenFlag := flag.String("token", "", "Токен Telegram бота")
-	debugModeFlag := flag.Bool("debug", false, "Режим отладки (true/false)")
-	maxConcurrentDownloads := flag.Int("concurrent", 5, "Максимальное количество одновременных скачиваний")
+	configPath := flag.String("config", defaultConfigPath, "Путь к YAML-файлу конфигурации")
+	registerConfigFlags()
 	flag.Parse()
 
-	adminID, _ = strconv.ParseInt(os.Getenv("BOT_ADMIN_ID"), 10, 64)
+	// Файл по умолчанию необязателен, явно указанный — должен существовать
+	configRequired := false
+	flag.Visit(func(f *flag.Flag) { configRequired = configRequired || f.Name == "config" })
+
+	cfg, err := loadConfig(*configPath, configRequired)
+	if err != nil {
+		log.Fatalf("Ошибка конфигурации: %v", err)
+	}
+
+	logger, err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.JSON)
+	if err != nil {
+		log.Fatalf("Ошибка настройки логирования: %v", err)
+	}
+	tgbotapi.SetLogger(slog.NewLogLogger(logger.Handler(), slog.LevelDebug))
+
+	dataDir = cfg.DataDir
 
 	if err := checkYtDlpAvailability(); err != nil {
-		log.Printf("Предупреждение: yt-dlp недоступен, YouTube функционал будет отключен: %v", err)
+		slog.Warn("yt-dlp недоступен, YouTube функционал будет отключен", "error", err)
 	} else {
-		log.Println("yt-dlp обнаружен, YouTube функционал включен")
+		slog.Info("yt-dlp обнаружен, YouTube функционал включен")
 	}
 
-	downloadSemaphore = make(chan struct{}, *maxConcurrentDownloads)
+	downloadScheduler = newScheduler(cfg.Downloads.Concurrent)
 
-	botToken := *botTokenFlag
-	if botToken == "" {
-		botToken = os.Getenv("TELEGRAM_BOT_TOKEN")
-		if botToken == "" {
-			log.Fatal("Токен бота не найден. Установите переменную окружения TELEGRAM_BOT_TOKEN или используйте флаг -token")
-		}
-	}
+	botStats = newStatsStore()
+	defer botStats.flush()
+	go botStats.startPeriodicFlush()
 
-	client, err := tgbotapi.NewBotAPI(botToken)
+	rateLimiter = newLimiter()
+	defer rateLimiter.flush()
+	go rateLimiter.startPeriodicFlush()
+	botAdmin = newAdminStore()
+	languages = newLanguageStore()
+	groupSettings = newChatSettingsStore()
+	deepLinks = newDeepLinkStore()
+	defer deepLinks.flush()
+	go deepLinks.startPeriodicFlush()
+	applyConfig(cfg)
+
+	client, err := tgbotapi.NewBotAPI(cfg.Token)
 	if err != nil {
-		log.Fatalf("Ошибка инициализации бота: %v", err)
+		fatal("Ошибка инициализации бота", "error", err)
 	}
 
-	client.Debug = *debugModeFlag
-	log.Printf("Авторизован как %s (Режим отладки: %v)", client.Self.UserName, client.Debug)
+	client.Client = instrumentedClient{inner: client.Client}
+	client.Debug = cfg.Debug
+	slog.Info("Бот авторизован", "username", client.Self.UserName, "debug", client.Debug)
 
 	setupBotCommands(client)
 
 	go startPeriodicCleanup()
 
+	if cfg.Metrics != "" {
+		go startMetricsServer(cfg.Metrics)
+	}
+
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 30
 
-	updates := client.GetUpdatesChan(updateConfig)
+	var updates <-chan botUpdate
+	var stopPolling func() int
+	var connectionErrors chan error
+
+	if cfg.Webhook.URL != "" {
+		var stopWebhook func()
+		updates, stopWebhook, err = startWebhook(client, cfg.Webhook)
+		if err != nil {
+			fatal("Ошибка запуска вебхука", "error", err)
+		}
+		defer stopWebhook()
+	} else {
+		// getUpdates не работает, пока зарегистрирован вебхук
+		if _, err := client.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			slog.Warn("Не удалось удалить вебхук перед запуском long polling", "error", err)
+		}
 
-	connectionErrors := make(chan error)
-	reconnect := make(chan struct{})
+		updates, stopPolling = pollUpdates(client, updateConfig)
 
-	// Запускаем мониторинг соединения с Telegram API
-	go monitorConnection(client, connectionErrors, reconnect)
+		connectionErrors = make(chan error)
 
-	shutdownCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		// Запускаем мониторинг соединения с Telegram API
+		go monitorConnection(client, connectionErrors)
+	}
+
+	var stop context.CancelFunc
+	shutdownCtx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+
 	wg := &sync.WaitGroup{}
 
 	for {
@@ -105,34 +158,58 @@ func main() {
 				wg.Add(1)
 				go func() {
 					defer wg.Done()
-					handleMessage(client, update.Message)
+					handleMessage(client, update.Message, update.TopicID)
+				}()
+			}
+			if update.InlineQuery != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handleInlineQuery(client, update.InlineQuery)
+				}()
+			}
+			if update.CallbackQuery != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handleCallback(client, update.CallbackQuery)
 				}()
 			}
 		case <-shutdownCtx.Done():
-			log.Println("Получен сигнал завершения, ожидаем активные загрузки...")
+			slog.Info("Получен сигнал завершения, ожидаем активные загрузки...")
 			waitCh := make(chan struct{})
 			go func() { wg.Wait(); close(waitCh) }()
 			select {
 			case <-waitCh:
-				log.Println("Все загрузки завершены")
+				slog.Info("Все загрузки завершены")
 			case <-time.After(30 * time.Second):
-				log.Println("Таймаут 30с, принудительный выход")
+				slog.Warn("Таймаут 30с, принудительный выход")
 			}
 			return
+		case <-reloadSignals:
+			reloadConfig(client, *configPath, configRequired)
 		case err := <-connectionErrors:
 			if !strings.Contains(err.Error(), "timeout") && !strings.Contains(err.Error(), "EOF") {
-				log.Printf("Ошибка соединения с Telegram API: %v", err)
+				slog.Error("Ошибка соединения с Telegram API", "error", err)
 			}
 
-			reconnect <- struct{}{}
-		case <-reconnect:
-			updates = client.GetUpdatesChan(updateConfig)
+			// Новый опрос начинается после завершения старого и с его offset:
+			// иначе параллельные getUpdates получают 409 Conflict, а последние
+			// обновления обрабатываются повторно
+			updateConfig.Offset = stopPolling()
+			updates, stopPolling = pollUpdates(client, updateConfig)
 		}
 	}
 }
 
+// fatal пишет ошибку в лог и завершает процесс
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
 // monitorConnection следит за соединением с Telegram API
-func monitorConnection(bot *tgbotapi.BotAPI, errorChan chan<- error, reconnect chan<- struct{}) {
+func monitorConnection(bot *tgbotapi.BotAPI, errorChan chan<- error) {
 	ticker := time.NewTicker(10 * time.Minute)
 	defer ticker.Stop()
 
@@ -144,25 +221,52 @@ func monitorConnection(bot *tgbotapi.BotAPI, errorChan chan<- error, reconnect c
 	}
 }
 
+// userCommands — команды в меню бота; описания берутся из каталога i18n по ключу command.<имя>
+var userCommands = []string{"start", "help", "lang", "gif", "clip", "note"}
+
+// groupCommands — команды, которые показываются только в меню групп
+var groupCommands = []string{"dl", "settings"}
+
 func setupBotCommands(bot *tgbotapi.BotAPI) {
-	commands := []tgbotapi.BotCommand{
-		{Command: "start", Description: "Начать работу с ботом"},
-		{Command: "help", Description: "Показать инструкцию по использованию"},
-		{Command: "stats", Description: "Статистика бота (только для администратора)"},
+	// Меню без language_code видят пользователи с неподдерживаемыми языками, им бот отвечает по-английски
+	menus := map[string]i18n.Lang{"": i18n.EN}
+	for _, lang := range i18n.Supported {
+		menus[string(lang)] = lang
 	}
 
-	_, err := bot.Request(tgbotapi.NewSetMyCommands(commands...))
-	if err != nil {
-		log.Printf("Ошибка при установке команд бота: %v", err)
-	}
+	for code, lang := range menus {
+		for scope, commands := range map[tgbotapi.BotCommandScope][]tgbotapi.BotCommand{
+			tgbotapi.NewBotCommandScopeDefault():       botCommands(lang, userCommands),
+			tgbotapi.NewBotCommandScopeAllGroupChats(): botCommands(lang, userCommands, groupCommands),
+		} {
+			_, err := bot.Request(tgbotapi.NewSetMyCommandsWithScopeAndLanguage(scope, code, commands...))
+			if err != nil {
+				slog.Error("Ошибка при установке команд бота", "scope", scope.Type, "language", code, "error", err)
+			}
+		}
 
-	scope := tgbotapi.NewBotCommandScopeAllGroupChats()
-	_, err = bot.Request(tgbotapi.NewSetMyCommandsWithScope(scope, commands...))
-	if err != nil {
-		log.Printf("Ошибка при установке команд бота для групповых чатов: %v", err)
+		// Администраторы видят в личном чате и свои команды
+		commands := botCommands(lang, userCommands, adminCommands)
+		for id := range currentConfig().admins {
+			scope := tgbotapi.NewBotCommandScopeChat(id)
+			_, err := bot.Request(tgbotapi.NewSetMyCommandsWithScopeAndLanguage(scope, code, commands...))
+			if err != nil {
+				slog.Error("Ошибка при установке команд администратора", "admin", id, "language", code, "error", err)
+			}
+		}
 	}
 }
 
+// botCommands собирает команды меню с описаниями на языке lang
+func botCommands(lang i18n.Lang, lists ...[]string) []tgbotapi.BotCommand {
+	var commands []tgbotapi.BotCommand
+	for _, names := range lists {
+		for _, name := range names {
+			commands = append(commands, tgbotapi.BotCommand{Command: name, Description: lang.T("command." + name)})
+		}
+	}
+	return commands
+}
 
 func isJustLink(text string, regex *regexp.Regexp) bool {
 	trimmedText := strings.TrimSpace(text)
@@ -175,331 +279,813 @@ func isJustLink(text string, regex *regexp.Regexp) bool {
 	return len(trimmedText) == len(matches[0])
 }
 
-func extractLink(text string) string {
-	instagramMatches := instagramRegex.FindStringSubmatch(text)
-	if len(instagramMatches) > 0 {
-		return instagramMatches[0]
+// hasTriggerLink сообщает, есть ли в сообщении группы ссылка, на которую бот
+// реагирует без упоминания: в режиме linkModeAny — любая поддерживаемая ссылка,
+// в режиме linkModeBare — текст или подпись, целиком состоящие из одной ссылки.
+// Пересланные сообщения проверяются так же, как обычные.
+func hasTriggerLink(message *tgbotapi.Message, mode string) bool {
+	if mode == linkModeAny {
+		return len(extractLinks(message)) > 0
 	}
+	return isBareLink(message.Text, message.Entities) || isBareLink(message.Caption, message.CaptionEntities)
+}
 
-	twitterMatches := twitterRegex.FindStringSubmatch(text)
-	if len(twitterMatches) > 0 {
-		return twitterMatches[0]
+// isBareLink проверяет, что текст — одна поддерживаемая ссылка: обычная
+// или оформленная сущностью text_link на весь текст
+func isBareLink(text string, entities []tgbotapi.MessageEntity) bool {
+	for _, regex := range []*regexp.Regexp{instagramRegex, twitterRegex, tiktokRegex, facebookRegex, youtubeRegex} {
+		if isJustLink(text, regex) {
+			return true
+		}
 	}
 
-	tiktokMatches := tiktokRegex.FindStringSubmatch(text)
-	if len(tiktokMatches) > 0 {
-		return tiktokMatches[0]
+	trimmed := strings.TrimSpace(text)
+	for _, entity := range entities {
+		if entity.Type != "text_link" || strings.TrimSpace(entityText(text, entity)) != trimmed {
+			continue
+		}
+		if _, ok := matchLink(entity.URL); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionsBot проверяет, упомянут ли бот в тексте или подписи сообщения
+func mentionsBot(message *tgbotapi.Message, username string) bool {
+	check := func(text string, entities []tgbotapi.MessageEntity) bool {
+		for _, entity := range entities {
+			if entity.Type == "mention" && strings.EqualFold(entityText(text, entity), "@"+username) {
+				return true
+			}
+		}
+		return false
 	}
+	return check(message.Text, message.Entities) || check(message.Caption, message.CaptionEntities)
+}
 
-	facebookMatches := facebookRegex.FindStringSubmatch(text)
-	if len(facebookMatches) > 0 {
-		return facebookMatches[0]
+// matchLink проверяет, является ли кандидат поддерживаемой ссылкой, и нормализует её
+func matchLink(candidate string) (string, bool) {
+	for _, regex := range []*regexp.Regexp{instagramRegex, twitterRegex, tiktokRegex, facebookRegex} {
+		if match := regex.FindString(candidate); match != "" {
+			return match, true
+		}
 	}
 
-	youtubeMatches := youtubeRegex.FindStringSubmatch(text)
-	if len(youtubeMatches) > 0 {
-		link := youtubeMatches[0]
-		if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
-			link = "https://" + link
+	if match := youtubeRegex.FindString(candidate); match != "" {
+		if !strings.HasPrefix(match, "http://") && !strings.HasPrefix(match, "https://") {
+			match = "https://" + match
 		}
-		return link
+		return match, true
 	}
 
-	return text
+	return "", false
 }
 
-func handleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+// linkPunctuation — символы, которые отрезаются по краям слова перед поиском ссылки
+const linkPunctuation = "()[]<>{}\"'«».,;:!"
+
+// extractLinks находит все поддерживаемые ссылки в тексте, подписи
+// и сущностях url/text_link сообщения, без повторов и в порядке появления
+func extractLinks(message *tgbotapi.Message) []string {
+	var candidates []string
+
+	collect := func(text string, entities []tgbotapi.MessageEntity) {
+		for _, entity := range entities {
+			switch entity.Type {
+			case "url":
+				candidates = append(candidates, entityText(text, entity))
+			case "text_link":
+				candidates = append(candidates, entity.URL)
+			}
+		}
+		for _, field := range strings.Fields(text) {
+			// Ссылки в тексте часто обрамлены скобками, кавычками или знаками препинания
+			candidates = append(candidates, strings.Trim(field, linkPunctuation))
+		}
+	}
+	collect(message.Text, message.Entities)
+	collect(message.Caption, message.CaptionEntities)
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		link, ok := matchLink(candidate)
+		if !ok || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// entityText вырезает текст сущности; смещения Telegram указаны в UTF-16
+func entityText(text string, entity tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if entity.Offset < 0 || entity.Offset+entity.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+}
+
+func handleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, topicID int) {
 	userID := message.From.ID
 	chatID := message.Chat.ID
 	isGroup := message.Chat.IsGroup() || message.Chat.IsSuperGroup()
 
-	if bot.Debug {
-		log.Printf("[%s] %s в чате %d (групповой: %v)", message.From.UserName, message.Text, chatID, isGroup)
+	lang := languages.messageLang(message)
+	ctx := logging.With(context.Background(), "job", logging.NewJobID(), "user", userID, "chat", chatID)
+	ctx = i18n.With(ctx, lang)
+	logger := logging.From(ctx)
+	logger.Debug("Получено сообщение", "username", message.From.UserName, "text", message.Text, "group", isGroup,
+		"forwarded", message.ForwardDate != 0)
+
+	if !isAdmin(userID) && botAdmin.isBanned(userID, chatID) {
+		logger.Debug("Сообщение от заблокированного пользователя или чата проигнорировано")
+		return
+	}
+	if !isGroup {
+		botAdmin.rememberUser(userID)
 	}
 
 	if isGroup {
-		mentionsBot := false
-		if message.Entities != nil {
-			for _, entity := range message.Entities {
-				if entity.Type == "mention" {
-					mention := message.Text[entity.Offset : entity.Offset+entity.Length]
-					if strings.Contains(mention, "@"+bot.Self.UserName) {
-						mentionsBot = true
-						break
-					}
-				}
-			}
-		}
+		settings := groupSettings.get(chatID)
 
-		if !message.IsCommand() && !mentionsBot &&
-			!isJustLink(message.Text, instagramRegex) &&
-			!isJustLink(message.Text, twitterRegex) &&
-			!isJustLink(message.Text, tiktokRegex) &&
-			!isJustLink(message.Text, facebookRegex) &&
-			!isJustLink(message.Text, youtubeRegex) {
+		// Без автоскачивания бот реагирует на ссылки только при упоминании
+		if !message.IsCommand() && !mentionsBot(message, bot.Self.UserName) &&
+			(!settings.AutoDownload || !hasTriggerLink(message, settings.LinkMode)) {
 			return
 		}
 	}
 
+	// Ссылка из deep link: /start <payload>
+	var startLink string
+
 	if message.IsCommand() {
 		switch message.Command() {
 		case "start":
+			payload := message.CommandArguments()
+			if payload != "" && payload != startInline && !isGroup {
+				link, err := payloadLink(payload)
+				if err != nil {
+					logger.Debug("Отклонён payload /start", "payload", payload, "error", err)
+					bot.Send(tgbotapi.NewMessage(chatID, lang.T("start.expired")))
+					return
+				}
+				startLink = link
+				inlineJobs.wait(ctx, link)
+				break
+			}
 			if isGroup {
-				msg := tgbotapi.NewMessage(chatID,
-					"Привет! Я готов скачивать видео из Instagram, Twitter, TikTok, Facebook и YouTube Shorts. Просто отправь мне ссылку.")
-				bot.Send(msg)
+				bot.Send(tgbotapi.NewMessage(chatID, lang.T("start.group")))
 			} else {
-				msg := tgbotapi.NewMessage(chatID,
-					"Привет! Я бот для скачивания видео из Instagram, Twitter (X), TikTok, Facebook и YouTube Shorts. "+
-						"Просто отправь мне ссылку на пост, и я сохраню для тебя видео.\n\n")
-				bot.Send(msg)
+				bot.Send(tgbotapi.NewMessage(chatID, lang.T("start")))
 			}
 			return
 		case "help":
-			helpText := "🔍 *Как использовать*:\n\n" +
-				"1. Найдите видео в Instagram, Twitter (X), TikTok, Facebook или YouTube Shorts\n" +
-				"2. Скопируйте ссылку на пост/видео\n" +
-				"3. Отправьте мне эту ссылку\n" +
-				"4. Дождитесь загрузки и получите видео\n\n" +
-				"*Поддерживаемые платформы*:\n" +
-				"• Instagram (посты и reels)\n" +
-				"• Twitter/X\n" +
-				"• TikTok\n" +
-				"• Facebook\n" +
-				"• YouTube Shorts (только короткие видео)\n\n" +
-				"*YouTube*: Поддерживаю только Shorts (youtube.com/shorts/). Для длинных видео используйте сторонние сайты.\n\n" +
-				"*В групповых чатах*: Я обрабатываю только ссылки на видео или сообщения, в которых меня упоминают (@" + bot.Self.UserName + ")"
+			helpText := strings.ReplaceAll(lang.T("help"), "{bot}", bot.Self.UserName)
 
 			msg := tgbotapi.NewMessage(chatID, helpText)
 			msg.ParseMode = "Markdown"
 			bot.Send(msg)
 			return
-		case "stats":
-			if adminID == 0 || userID != adminID {
+		case "lang":
+			handleLangCommand(ctx, bot, message)
+			return
+		case "settings":
+			handleSettingsCommand(ctx, bot, message)
+			return
+		case "stats", "ban", "unban", "broadcast", "maintenance", "provider", "deeplink":
+			if !isAdmin(userID) {
 				return
 			}
-			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
-				"Статистика:\nВсего загрузок: %d\nОшибок: %d\nВ очереди: %d\nАктивных: %d\nВремя работы: %v",
-				atomic.LoadInt64(&statTotal),
-				atomic.LoadInt64(&statErrors),
-				atomic.LoadInt64(&queuedCount),
-				atomic.LoadInt64(&runningCount),
-				time.Since(statStart).Round(time.Minute),
-			)))
+			handleAdminCommand(ctx, bot, message)
 			return
 		}
 	}
 
-	messageText := extractLink(message.Text)
+	target := newDelivery(ctx, message, topicID)
+	links := extractLinks(message)
+	if startLink != "" {
+		links = []string{startLink}
+	}
 
-	// Определяем платформу
-	var processingText string
-	switch {
-	case instagramRegex.MatchString(messageText):
-		processingText = "Обрабатываю Instagram ссылку..."
-	case twitterRegex.MatchString(messageText):
-		processingText = "Обрабатываю Twitter/X ссылку..."
-	case tiktokRegex.MatchString(messageText):
-		processingText = "Обрабатываю TikTok ссылку..."
-	case facebookRegex.MatchString(messageText):
-		processingText = "Обрабатываю Facebook ссылку..."
-	case youtubeRegex.MatchString(messageText):
-		processingText = "Обрабатываю YouTube ссылку..."
-	default:
+	// /dl, команда преобразования или упоминание бота ответом на сообщение
+	// скачивает ссылки из этого сообщения
+	command := ""
+	if message.IsCommand() {
+		command = message.Command()
+	}
+	isConvertCommand := command == "gif" || command == "clip" || command == "note"
+	isDownloadCommand := command == "dl" || isConvertCommand
+	var replyVideo telegramVideo
+	hasReplyVideo := false
+	if source := message.ReplyToMessage; source != nil && len(links) == 0 &&
+		(isDownloadCommand || mentionsBot(message, bot.Self.UserName)) {
+		links = extractLinks(source)
+		target = target.replyingTo(source)
+		ctx = logging.With(ctx, "reply_to", source.MessageID)
+		// Команда преобразования ответом на видео без ссылки преобразует само видео
+		if len(links) == 0 && isConvertCommand {
+			replyVideo, hasReplyVideo = messageVideo(source)
+		}
+	}
+	if isDownloadCommand && len(links) == 0 && !hasReplyVideo {
+		target.sendText(bot, lang.T(command+".usage"))
+		return
+	}
+	if isConvertCommand {
+		convert, err := commandConversion(command, message.CommandArguments())
+		if err != nil {
+			logger.Debug("Некорректные аргументы команды", "command", command, "error", err)
+			target.sendText(bot, lang.T(command+".usage"))
+			return
+		}
+		// Преобразуется только первая ссылка: альбом анимаций и фрагментов не собрать
+		target = target.converting(convert)
+		links = links[:min(len(links), 1)]
+	}
+
+	links = slices.DeleteFunc(links, func(link string) bool {
+		return !target.settings.platformAllowed(linkPlatform(link))
+	})
+	if len(links) == 0 && !hasReplyVideo {
 		if !isGroup {
 			normalYouTubeRegex := regexp.MustCompile(`^(?:https?://)?(?:www\.)?(?:youtube\.com/watch\?v=|youtu\.be/)([a-zA-Z0-9_-]{11})`)
-			if normalYouTubeRegex.MatchString(messageText) {
-				msg := tgbotapi.NewMessage(chatID,
-					"Я поддерживаю только YouTube Shorts (короткие видео).\n\n"+
-						"Ссылки должны быть вида: youtube.com/shorts/VIDEO_ID\n\n"+
-						"Для скачивания обычных YouTube видео используйте сторонние сайты, например:\n"+
-						"• savefrom.net\n"+
-						"• y2mate.com\n"+
-						"• 9xbuddy.com")
-				bot.Send(msg)
+			if normalYouTubeRegex.MatchString(strings.TrimSpace(message.Text)) {
+				target.sendText(bot, lang.T("youtube.not_shorts"))
 			} else {
-				msg := tgbotapi.NewMessage(chatID,
-					"Пожалуйста, отправьте ссылку на пост из Instagram, Twitter, TikTok, Facebook или YouTube Shorts, содержащий видео.")
-				bot.Send(msg)
+				target.sendText(bot, lang.T("not_a_link"))
 			}
 		}
 		return
 	}
 
-	// Ограничение: один запрос на пользователя одновременно
-	if _, loaded := activeUsers.LoadOrStore(userID, struct{}{}); loaded {
-		bot.Send(tgbotapi.NewMessage(chatID, "Ваша загрузка ещё обрабатывается, подождите..."))
+	if on, notice := botAdmin.maintenance(); on && !isAdmin(userID) {
+		if notice == "" {
+			notice = lang.T("maintenance")
+		}
+		target.sendText(bot, notice)
 		return
 	}
-	defer activeUsers.Delete(userID)
 
-	processingMsg, _ := bot.Send(tgbotapi.NewMessage(chatID, processingText))
+	jobs := len(links)
+	if hasReplyVideo {
+		jobs = 1
+	}
 
-	// Семафор с обратной связью о позиции в очереди
-	var queueMsg *tgbotapi.Message
-	select {
-	case downloadSemaphore <- struct{}{}:
-		// слот свободен, очередь не нужна
-	default:
-		atomic.AddInt64(&queuedCount, 1)
-		m, _ := bot.Send(tgbotapi.NewMessage(chatID,
-			fmt.Sprintf("Все слоты заняты, ожидайте... (в очереди: %d)", atomic.LoadInt64(&queuedCount))))
-		queueMsg = &m
-		downloadSemaphore <- struct{}{} // блокирующий захват
-		atomic.AddInt64(&queuedCount, -1)
+	// Ограничение на количество одновременных загрузок одного пользователя.
+	// Проверяется до лимитов, чтобы отклонённые и отброшенные ссылки не расходовали квоту.
+	accepted := reserveUserJobs(userID, jobs)
+	if accepted == 0 {
+		target.sendText(bot, lang.T("busy"))
+		return
 	}
-	defer func() { <-downloadSemaphore }()
+	defer releaseUserJobs(userID, accepted)
 
-	if queueMsg != nil {
-		bot.Request(tgbotapi.NewDeleteMessage(chatID, queueMsg.MessageID))
+	// Ограничение частоты запросов и дневные квоты
+	if err := rateLimiter.allow(userID, chatID, accepted); err != nil {
+		if limitErr, ok := err.(*limitError); ok {
+			target.sendText(bot, limitMessage(lang, limitErr))
+		}
+		return
 	}
 
-	atomic.AddInt64(&runningCount, 1)
-	defer atomic.AddInt64(&runningCount, -1)
+	if accepted < len(links) {
+		target.sendText(bot, lang.T("too_many_links", maxUserJobs))
+		links = links[:accepted]
+	}
 
-	dlCtx, dlCancel := context.WithTimeout(context.Background(), 3*time.Minute)
-	defer dlCancel()
+	if hasReplyVideo {
+		processTelegramVideo(ctx, bot, target, userID, replyVideo)
+		return
+	}
+	if len(links) > 1 {
+		processBatch(ctx, bot, target, userID, links)
+		return
+	}
 
-	var videoPath string
-	var err error
-	switch {
-	case instagramRegex.MatchString(messageText):
-		videoPath, err = downloader.DownloadInstagramVideo(dlCtx, messageText, userID)
-	case twitterRegex.MatchString(messageText):
-		videoPath, err = downloader.DownloadTwitterVideo(dlCtx, messageText, userID)
-	case tiktokRegex.MatchString(messageText):
-		videoPath, err = downloader.DownloadTikTokVideo(dlCtx, messageText, userID)
-	case facebookRegex.MatchString(messageText):
-		videoPath, err = downloader.DownloadFacebookVideo(dlCtx, messageText, userID)
-	case youtubeRegex.MatchString(messageText):
-		videoPath, err = downloader.DownloadYouTubeVideo(dlCtx, messageText, userID)
+	link := links[0]
+	ctx = logging.With(ctx, "link", link)
+	if sendCachedVideo(ctx, bot, target, link) {
+		target.deleteOriginal(ctx, bot)
+		botStats.recordCached(linkPlatform(link), userID, chatID)
+		rateLimiter.record(userID, 0)
+		return
+	}
+
+	processingMsg, _ := target.sendText(bot, processingText(lang, link))
+
+	if err := waitForSlot(ctx, bot, target, userID); err != nil {
+		dropQueuedJob(ctx, bot, target, processingMsg.MessageID, err)
+		return
+	}
+	start := time.Now()
+	media, err := downloadLink(ctx, link, userID)
+	downloadScheduler.release()
+	latency := time.Since(start)
+	if err == nil {
+		err = target.prepare(ctx, media)
 	}
+	botStats.recordJob(linkPlatform(link), userID, chatID, latency, err)
 
 	if err != nil {
-		log.Printf("Ошибка скачивания для пользователя %d: %v", userID, err)
-		atomic.AddInt64(&statErrors, 1)
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка при скачивании видео: %v", err))
-		bot.Send(errorMsg)
+		logging.From(ctx).Error("Ошибка скачивания", "error", err)
+		target.sendText(bot, errorText(lang, err))
 		go deleteMessageAfterDelay(bot, chatID, processingMsg.MessageID, 10)
 		return
 	}
 
-	atomic.AddInt64(&statTotal, 1)
-	sendVideo(bot, chatID, videoPath, userID, processingMsg.MessageID)
+	recordDownload(userID, media.Path)
+	sendVideo(ctx, bot, target, link, media, processingMsg.MessageID)
 	go cleanupOldFiles(userID)
 }
 
-func deleteMessageAfterDelay(bot *tgbotapi.BotAPI, chatID int64, messageID int, delaySeconds int) {
-	time.Sleep(time.Duration(delaySeconds) * time.Second)
-	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
-	if _, err := bot.Request(deleteMsg); err != nil {
-		log.Printf("Не удалось удалить сообщение %d: %v", messageID, err)
+// handleCallback обрабатывает нажатия на кнопки inline-клавиатур
+func handleCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	ctx := logging.With(context.Background(), "job", logging.NewJobID(), "user", query.From.ID, "chat", chatID)
+	ctx = i18n.With(ctx, languages.lang(query.Message.Chat, query.From))
+	logging.From(ctx).Debug("Получено нажатие кнопки", "data", query.Data)
+
+	switch {
+	case strings.HasPrefix(query.Data, settingsCallbackPrefix):
+		handleSettingsCallback(ctx, bot, query)
+	default:
+		bot.Request(tgbotapi.NewCallback(query.ID, ""))
+	}
+}
+
+// recordDownload учитывает размер скачанного файла в дневной квоте пользователя
+func recordDownload(userID int64, videoPath string) {
+	rateLimiter.record(userID, fileSize(videoPath))
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
 	}
+	return info.Size()
 }
 
-func getVideoDimensions(videoPath string) (width, height int) {
-	type probeOutput struct {
-		Streams []struct {
-			CodecType string `json:"codec_type"`
-			Width     int    `json:"width"`
-			Height    int    `json:"height"`
-		} `json:"streams"`
+// sendCachedVideo отправляет ранее загруженное в Telegram видео по file_id.
+// Возвращает false, если ссылки нет в кэше или отправка не удалась.
+func sendCachedVideo(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, link string) bool {
+	if !target.useCache() {
+		return false
 	}
-	out, err := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_streams", videoPath).Output()
+	cached, ok := videoCache.get(target.cacheKey(link))
+	if !ok {
+		return false
+	}
+
+	if err := sendFileID(bot, target, link, cached); err != nil {
+		logging.From(ctx).Warn("Не удалось отправить видео из кэша", "error", err)
+		return false
+	}
+
+	logging.From(ctx).Info("Видео отправлено из кэша")
+	metricCacheHits.Inc()
+	return true
+}
+
+// sendFileID отправляет ранее загруженный файл по file_id тем же способом, каким он был отправлен
+func sendFileID(bot *tgbotapi.BotAPI, target delivery, link string, cached cacheEntry) error {
+	method := sendMethods[cached.kind]
+	extra := target.captionParams(link, cached.meta)
+	switch cached.kind {
+	case fileVideo:
+		extra.AddBool("supports_streaming", true)
+	case fileVideoNote:
+		extra = tgbotapi.Params{} // у видеосообщений нет подписи
+	}
+
+	_, err := target.sendFile(bot, method[0], method[1], tgbotapi.FileID(cached.fileID), extra)
+	return err
+}
+
+// processingText возвращает текст служебного сообщения для ссылки
+func processingText(lang i18n.Lang, link string) string {
+	platform := linkPlatform(link)
+	if platform == "" {
+		platform = downloader.YouTube
+	}
+	return lang.T("processing." + string(platform))
+}
+
+// downloadLink скачивает видео по поддерживаемой ссылке.
+// Слот планировщика должен быть занят вызывающим кодом.
+func downloadLink(ctx context.Context, link string, userID int64) (*downloader.Media, error) {
+	dlCtx, dlCancel := context.WithTimeout(ctx, currentConfig().Downloads.Timeout)
+	defer dlCancel()
+
+	platform := linkPlatform(link)
+	download, ok := platformDownloaders[platform]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемая ссылка: %s", link)
+	}
+
+	start := time.Now()
+	media, err := download(dlCtx, link, userID)
+	metricDownloadDuration.WithLabelValues(string(platform)).Observe(time.Since(start).Seconds())
 	if err != nil {
-		return 0, 0
+		return nil, err
+	}
+
+	metricBytes.WithLabelValues("download").Add(float64(fileSize(media.Path)))
+	return media, nil
+}
+
+// platformDownloaders — функции скачивания для каждой платформы
+var platformDownloaders = map[downloader.PlatformType]func(context.Context, string, int64) (*downloader.Media, error){
+	downloader.Instagram: downloader.DownloadInstagramVideo,
+	downloader.Twitter:   downloader.DownloadTwitterVideo,
+	downloader.TikTok:    downloader.DownloadTikTokVideo,
+	downloader.Facebook:  downloader.DownloadFacebookVideo,
+	downloader.YouTube:   downloader.DownloadYouTubeVideo,
+}
+
+// linkPlatform определяет платформу по ссылке; для неподдерживаемых ссылок возвращает ""
+func linkPlatform(link string) downloader.PlatformType {
+	switch {
+	case instagramRegex.MatchString(link):
+		return downloader.Instagram
+	case twitterRegex.MatchString(link):
+		return downloader.Twitter
+	case tiktokRegex.MatchString(link):
+		return downloader.TikTok
+	case facebookRegex.MatchString(link):
+		return downloader.Facebook
+	case youtubeRegex.MatchString(link):
+		return downloader.YouTube
+	default:
+		return ""
 	}
-	var data probeOutput
-	if err := json.Unmarshal(out, &data); err != nil {
-		return 0, 0
+}
+
+// isPriorityUser сообщает, обслуживается ли пользователь вне общей очереди
+func isPriorityUser(userID int64) bool {
+	return isAdmin(userID) || currentConfig().priorityUsers[userID]
+}
+
+// acquireSlot занимает слот планировщика для задачи пользователя. Ожидание
+// прерывается отменой ctx, завершением бота и снятием задачи с очереди.
+func acquireSlot(ctx context.Context, userID, chatID int64, onPosition func(int)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(shutdownCtx, cancel)
+	defer stop()
+
+	return downloadScheduler.acquire(ctx, userID, chatID, isPriorityUser(userID), onPosition)
+}
+
+// waitForSlot ждёт слот планировщика, показывая и обновляя позицию в очереди.
+// Если задачу сняли с очереди, слот не занят и возвращается ошибка.
+func waitForSlot(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, userID int64) error {
+	chatID := target.chatID
+	var queueMsg *tgbotapi.Message
+	lastPos := 0
+
+	logger := logging.From(ctx).With("stage", "queue")
+
+	err := acquireSlot(ctx, userID, chatID, func(pos int) {
+		if pos == lastPos {
+			return
+		}
+		lastPos = pos
+		logger.Debug("Позиция в очереди изменилась", "position", pos)
+		text := i18n.From(ctx).T("queue.position", pos)
+		if queueMsg == nil {
+			if m, err := target.sendText(bot, text); err == nil {
+				queueMsg = &m
+			}
+			return
+		}
+		if _, err := bot.Request(tgbotapi.NewEditMessageText(chatID, queueMsg.MessageID, text)); err != nil {
+			logger.Warn("Не удалось обновить позицию в очереди", "error", err)
+		}
+	})
+
+	if queueMsg != nil {
+		bot.Request(tgbotapi.NewDeleteMessage(chatID, queueMsg.MessageID))
 	}
-	for _, s := range data.Streams {
-		if s.CodecType == "video" {
-			return s.Width, s.Height
+	return err
+}
+
+// dropQueuedJob завершает задачу, снятую с очереди: удаляет служебное сообщение,
+// а в режиме обслуживания отправляет уведомление. При блокировке и завершении
+// бота пользователь ничего не получает.
+func dropQueuedJob(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, processingMsgID int, err error) {
+	logging.From(ctx).Info("Задача снята с очереди", "reason", err)
+	bot.Request(tgbotapi.NewDeleteMessage(target.chatID, processingMsgID))
+
+	if on, notice := botAdmin.maintenance(); on && errors.Is(err, errQueueDropped) {
+		if notice == "" {
+			notice = target.lang.T("maintenance")
 		}
+		target.sendText(bot, notice)
+	}
+}
+
+func deleteMessageAfterDelay(bot *tgbotapi.BotAPI, chatID int64, messageID int, delaySeconds int) {
+	time.Sleep(time.Duration(delaySeconds) * time.Second)
+	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
+	if _, err := bot.Request(deleteMsg); err != nil {
+		slog.Warn("Не удалось удалить сообщение", "chat", chatID, "message_id", messageID, "error", err)
 	}
-	return 0, 0
 }
 
-func sendVideoWithDimensions(bot *tgbotapi.BotAPI, chatID int64, videoPath string, width, height int) error {
+// sendVideoWithDimensions отправляет видео с размерами и длительностью собственным multipart-запросом и возвращает file_id
+func sendVideoWithDimensions(bot *tgbotapi.BotAPI, target delivery, videoPath, thumbnail string, caption tgbotapi.Params, info downloader.VideoInfo) (string, error) {
 	f, err := os.Open(videoPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer f.Close()
 
 	var buf bytes.Buffer
 	w := multipart.NewWriter(&buf)
-	_ = w.WriteField("chat_id", strconv.FormatInt(chatID, 10))
-	_ = w.WriteField("width", strconv.Itoa(width))
-	_ = w.WriteField("height", strconv.Itoa(height))
+	for key, value := range target.params() {
+		_ = w.WriteField(key, value)
+	}
+	_ = w.WriteField("width", strconv.Itoa(info.Width))
+	_ = w.WriteField("height", strconv.Itoa(info.Height))
+	if seconds := info.Seconds(); seconds > 0 {
+		_ = w.WriteField("duration", strconv.Itoa(seconds))
+	}
 	_ = w.WriteField("supports_streaming", "true")
+	for key, value := range caption {
+		_ = w.WriteField(key, value)
+	}
 	part, err := w.CreateFormFile("video", filepath.Base(videoPath))
 	if err != nil {
-		return err
+		return "", err
 	}
 	if _, err := io.Copy(part, f); err != nil {
-		return err
+		return "", err
+	}
+	if thumbnail != "" {
+		if err := writeThumbnail(w, thumbnail); err != nil {
+			return "", err
+		}
 	}
 	w.Close()
 
 	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendVideo", bot.Token)
 	req, err := http.NewRequest("POST", apiURL, &buf)
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.Header.Set("Content-Type", w.FormDataContentType())
 
 	resp, err := bot.Client.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	var apiResp struct {
-		OK          bool   `json:"ok"`
-		Description string `json:"description"`
+		OK          bool             `json:"ok"`
+		Description string           `json:"description"`
+		Result      tgbotapi.Message `json:"result"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return err
+		return "", err
 	}
 	if !apiResp.OK {
-		return fmt.Errorf("telegram API: %s", apiResp.Description)
+		return "", fmt.Errorf("telegram API: %s", apiResp.Description)
 	}
-	return nil
+	return messageFileID(apiResp.Result), nil
 }
 
-func sendVideo(bot *tgbotapi.BotAPI, chatID int64, videoPath string, userID int64, processingMsgID int) {
+// writeThumbnail добавляет обложку в multipart-запрос как attach://
+func writeThumbnail(w *multipart.Writer, thumbnail string) error {
+	f, err := os.Open(thumbnail)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_ = w.WriteField("thumbnail", "attach://"+thumbnailAttachName)
+	part, err := w.CreateFormFile(thumbnailAttachName, filepath.Base(thumbnail))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
+
+// uploadMedia отправляет скачанный файл способом, который выбирает delivery.fileKind:
+// как видео, анимацию, аудио в режиме «только аудио», документ или видеосообщение.
+func uploadMedia(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, media *downloader.Media, caption tgbotapi.Params) (string, error) {
+	switch target.fileKind(media) {
+	case fileAudio:
+		return uploadAudio(ctx, bot, target, media.Path, media.Thumbnail, caption)
+	case fileAnimation:
+		return uploadAnimation(ctx, bot, target, media.Path, media.Thumbnail, caption)
+	case fileDocument:
+		return uploadDocument(ctx, bot, target, media.Path, media.Thumbnail, caption)
+	case fileVideoNote:
+		return uploadVideoNote(ctx, bot, target, media.Path, media.Thumbnail)
+	}
+	return uploadVideo(ctx, bot, target, media.Path, media.Thumbnail, caption)
+}
+
+// uploadAudio отправляет звуковую дорожку в чат и возвращает file_id
+func uploadAudio(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, audioPath, thumbnail string, caption tgbotapi.Params) (string, error) {
+	logger := logging.From(ctx).With("stage", "upload")
+	start := time.Now()
+	defer func() { metricUploadDuration.Observe(time.Since(start).Seconds()) }()
+
+	extra := tgbotapi.Params{}
+	extra.AddNonZero("duration", downloader.ProbeVideo(audioPath).Seconds())
+	for key, value := range caption {
+		extra[key] = value
+	}
+	attachments := attachThumbnail(extra, thumbnail)
+	msg, err := target.sendFile(bot, "sendAudio", "audio", tgbotapi.FilePath(audioPath), extra, attachments...)
+	if err != nil {
+		logger.Error("Ошибка при отправке аудио", "error", err, "path", audioPath)
+		return "", err
+	}
+
+	size := fileSize(audioPath)
+	metricBytes.WithLabelValues("upload").Add(float64(size))
+	logger.Info("Аудио отправлено", "bytes", size, "elapsed", time.Since(start))
+	return messageFileID(msg), nil
+}
+
+// uploadAnimation отправляет анимацию (MP4 без звука или GIF), которая проигрывается
+// по кругу, и возвращает file_id
+func uploadAnimation(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, path, thumbnail string, caption tgbotapi.Params) (string, error) {
+	logger := logging.From(ctx).With("stage", "upload")
+	start := time.Now()
+	defer func() { metricUploadDuration.Observe(time.Since(start).Seconds()) }()
+
+	info := downloader.ProbeVideo(path)
+	extra := tgbotapi.Params{}
+	extra.AddNonZero("width", info.Width)
+	extra.AddNonZero("height", info.Height)
+	extra.AddNonZero("duration", info.Seconds())
+	for key, value := range caption {
+		extra[key] = value
+	}
+	attachments := attachThumbnail(extra, thumbnail)
+	msg, err := target.sendFile(bot, "sendAnimation", "animation", tgbotapi.FilePath(path), extra, attachments...)
+	if err != nil {
+		logger.Error("Ошибка при отправке анимации", "error", err, "path", path)
+		return "", err
+	}
+
+	size := fileSize(path)
+	metricBytes.WithLabelValues("upload").Add(float64(size))
+	logger.Info("Анимация отправлена", "bytes", size, "elapsed", time.Since(start))
+	return messageFileID(msg), nil
+}
+
+// uploadDocument отправляет файл как документ без определения типа на сервере,
+// чтобы Telegram не перекодировал его, и возвращает file_id
+func uploadDocument(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, path, thumbnail string, caption tgbotapi.Params) (string, error) {
+	logger := logging.From(ctx).With("stage", "upload")
+	start := time.Now()
+	defer func() { metricUploadDuration.Observe(time.Since(start).Seconds()) }()
+
+	extra := tgbotapi.Params{}
+	extra.AddBool("disable_content_type_detection", true)
+	for key, value := range caption {
+		extra[key] = value
+	}
+	attachments := attachThumbnail(extra, thumbnail)
+	msg, err := target.sendFile(bot, "sendDocument", "document", tgbotapi.FilePath(path), extra, attachments...)
+	if err != nil {
+		logger.Error("Ошибка при отправке файла", "error", err, "path", path)
+		return "", err
+	}
+
+	size := fileSize(path)
+	metricBytes.WithLabelValues("upload").Add(float64(size))
+	logger.Info("Файл отправлен", "bytes", size, "elapsed", time.Since(start))
+	return messageFileID(msg), nil
+}
+
+// uploadVideoNote отправляет видеосообщение и возвращает file_id. Подписи
+// у видеосообщений нет; length — сторона квадрата.
+func uploadVideoNote(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, path, thumbnail string) (string, error) {
+	logger := logging.From(ctx).With("stage", "upload")
+	start := time.Now()
+	defer func() { metricUploadDuration.Observe(time.Since(start).Seconds()) }()
+
+	info := downloader.ProbeVideo(path)
+	extra := tgbotapi.Params{}
+	extra.AddNonZero("length", info.Width)
+	extra.AddNonZero("duration", info.Seconds())
+	attachments := attachThumbnail(extra, thumbnail)
+	msg, err := target.sendFile(bot, "sendVideoNote", "video_note", tgbotapi.FilePath(path), extra, attachments...)
+	if err != nil {
+		logger.Error("Ошибка при отправке видеосообщения", "error", err, "path", path)
+		return "", err
+	}
+
+	size := fileSize(path)
+	metricBytes.WithLabelValues("upload").Add(float64(size))
+	logger.Info("Видеосообщение отправлено", "bytes", size, "elapsed", time.Since(start))
+	return messageFileID(msg), nil
+}
+
+// uploadVideo отправляет файл видео в чат с корректными размерами и возвращает file_id
+func uploadVideo(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, videoPath, thumbnail string, caption tgbotapi.Params) (string, error) {
+	logger := logging.From(ctx).With("stage", "upload")
+	start := time.Now()
+	defer func() { metricUploadDuration.Observe(time.Since(start).Seconds()) }()
+
+	var fileID string
 	var err error
+
+	info := downloader.ProbeVideo(videoPath)
+	if info.Width == 0 || info.Height == 0 {
+		logger.Debug("Не удалось определить размеры видео", "path", videoPath)
+	}
+	logger.Debug("Параметры видео", "width", info.Width, "height", info.Height, "duration", info.Duration,
+		"video_codec", info.VideoCodec, "audio_codec", info.AudioCodec)
+	if info.Width > 0 && info.Height > 0 {
+		fileID, err = sendVideoWithDimensions(bot, target, videoPath, thumbnail, caption, info)
+	} else {
+		extra := tgbotapi.Params{}
+		extra.AddBool("supports_streaming", true)
+		extra.AddNonZero("duration", info.Seconds())
+		for key, value := range caption {
+			extra[key] = value
+		}
+		attachments := attachThumbnail(extra, thumbnail)
+		var msg tgbotapi.Message
+		msg, err = target.sendFile(bot, "sendVideo", "video", tgbotapi.FilePath(videoPath), extra, attachments...)
+		fileID = messageFileID(msg)
+	}
+
+	if err != nil {
+		logger.Error("Ошибка при отправке видео", "error", err, "path", videoPath)
+		return "", err
+	}
+
+	size := fileSize(videoPath)
+	metricBytes.WithLabelValues("upload").Add(float64(size))
+	logger.Info("Видео отправлено", "bytes", size, "width", info.Width, "height", info.Height, "duration", info.Duration,
+		"thumbnail", thumbnail != "", "elapsed", time.Since(start))
+	return fileID, nil
+}
+
+// messageFileID возвращает file_id видео, анимации, аудио или видеосообщения из отправленного сообщения
+func messageFileID(msg tgbotapi.Message) string {
+	switch {
+	case msg.VideoNote != nil:
+		return msg.VideoNote.FileID
+	case msg.Audio != nil:
+		return msg.Audio.FileID
+	case msg.Video != nil:
+		return msg.Video.FileID
+	case msg.Animation != nil:
+		return msg.Animation.FileID
+	case msg.Document != nil:
+		return msg.Document.FileID
+	}
+	return ""
+}
+
+func sendVideo(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, link string, media *downloader.Media, processingMsgID int) {
+	ctx = logging.With(ctx, "platform", media.Platform, "provider", media.Provider)
+	logger := logging.From(ctx)
+	chatID := target.chatID
+	videoPath := media.Path
 	videoSent := false
 
 	defer func() {
 		deleteMsg := tgbotapi.NewDeleteMessage(chatID, processingMsgID)
 		if _, delErr := bot.Request(deleteMsg); delErr != nil {
-			log.Printf("Не удалось удалить служебное сообщение %d: %v", processingMsgID, delErr)
+			logger.Warn("Не удалось удалить служебное сообщение", "message_id", processingMsgID, "error", delErr)
 		}
 		if videoSent {
-			if fileErr := os.Remove(videoPath); fileErr != nil {
-				log.Printf("Не удалось удалить временный файл %s: %v", videoPath, fileErr)
+			if fileErr := media.Remove(); fileErr != nil {
+				logger.Warn("Не удалось удалить временный файл", "path", videoPath, "error", fileErr)
 			}
 		}
 	}()
 
-	width, height := getVideoDimensions(videoPath)
-	if width > 0 && height > 0 {
-		err = sendVideoWithDimensions(bot, chatID, videoPath, width, height)
-	} else {
-		video := tgbotapi.NewVideo(chatID, tgbotapi.FilePath(videoPath))
-		video.SupportsStreaming = true
-		_, err = bot.Send(video)
-	}
-
+	fileID, err := uploadMedia(ctx, bot, target, media, target.captionParams(link, media.Meta))
 	if err != nil {
-		log.Printf("Ошибка при отправке видео пользователю %d: %v", userID, err)
-		errorMsg := tgbotapi.NewMessage(chatID, "Не удалось отправить видео. Попробуйте еще раз.")
-		bot.Send(errorMsg)
+		target.sendText(bot, i18n.From(ctx).T("error.send"))
 	} else {
 		videoSent = true
+		if link != "" {
+			videoCache.put(target.cacheKey(link), fileID, target.fileKind(media), media.Meta)
+		}
+		target.deleteOriginal(ctx, bot)
 	}
 }
 
@@ -513,7 +1099,7 @@ func cleanupOldFiles(userID int64) {
 
 	files, err := os.ReadDir(userDir)
 	if err != nil {
-		log.Printf("Ошибка при чтении директории пользователя %d: %v", userID, err)
+		slog.Error("Ошибка при чтении директории пользователя", "user", userID, "error", err)
 		return
 	}
 
@@ -530,21 +1116,21 @@ func cleanupOldFiles(userID int64) {
 			continue
 		}
 
-		if now.Sub(fileInfo.ModTime()) > time.Hour {
+		if now.Sub(fileInfo.ModTime()) > currentConfig().Cleanup.UserFilesAge {
 			if err := os.Remove(filePath); err != nil {
-				log.Printf("Ошибка при удалении старого файла %s: %v", filePath, err)
+				slog.Error("Ошибка при удалении старого файла", "path", filePath, "error", err)
 			} else {
-				log.Printf("Удален старый файл: %s", filePath)
+				slog.Info("Удален старый файл", "path", filePath)
 			}
 		}
 	}
 }
 
 func startPeriodicCleanup() {
-	ticker := time.NewTicker(1 * time.Hour)
+	ticker := time.NewTicker(currentConfig().Cleanup.Interval)
 	defer ticker.Stop()
 
-	log.Println("Запущена периодическая очистка временных файлов")
+	slog.Info("Запущена периодическая очистка временных файлов")
 
 	for range ticker.C {
 		cleanupAllTempFiles()
@@ -552,7 +1138,7 @@ func startPeriodicCleanup() {
 }
 
 func cleanupAllTempFiles() {
-	log.Println("Начинаем очистку всех временных файлов...")
+	slog.Info("Начинаем очистку всех временных файлов...")
 
 	tempDir := "temp_videos"
 
@@ -563,7 +1149,7 @@ func cleanupAllTempFiles() {
 
 	userDirs, err := os.ReadDir(tempDir)
 	if err != nil {
-		log.Printf("Ошибка при чтении директории временных файлов: %v", err)
+		slog.Error("Ошибка при чтении директории временных файлов", "error", err)
 		return
 	}
 
@@ -576,7 +1162,7 @@ func cleanupAllTempFiles() {
 
 		files, err := os.ReadDir(userDirPath)
 		if err != nil {
-			log.Printf("Ошибка при чтении директории пользователя %s: %v", userDir.Name(), err)
+			slog.Error("Ошибка при чтении директории пользователя", "dir", userDir.Name(), "error", err)
 			continue
 		}
 
@@ -595,11 +1181,11 @@ func cleanupAllTempFiles() {
 				continue
 			}
 
-			if now.Sub(fileInfo.ModTime()) > 24*time.Hour {
+			if now.Sub(fileInfo.ModTime()) > currentConfig().Cleanup.TempFilesAge {
 				if err := os.Remove(filePath); err != nil {
-					log.Printf("Ошибка при удалении старого файла %s: %v", filePath, err)
+					slog.Error("Ошибка при удалении старого файла", "path", filePath, "error", err)
 				} else {
-					log.Printf("Удален старый файл: %s", filePath)
+					slog.Info("Удален старый файл", "path", filePath)
 				}
 			} else {
 				hasFiles = true
@@ -608,14 +1194,14 @@ func cleanupAllTempFiles() {
 
 		if !hasFiles {
 			if err := os.Remove(userDirPath); err != nil {
-				log.Printf("Ошибка при удалении пустой директории пользователя %s: %v", userDirPath, err)
+				slog.Error("Ошибка при удалении пустой директории пользователя", "path", userDirPath, "error", err)
 			} else {
-				log.Printf("Удалена пустая директория пользователя: %s", userDirPath)
+				slog.Info("Удалена пустая директория пользователя", "path", userDirPath)
 			}
 		}
 	}
 
-	log.Println("Очистка временных файлов завершена")
+	slog.Info("Очистка временных файлов завершена")
 }
 
 func checkYtDlpAvailability() error {
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"goland/VideoSaverBot/downloader"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricDownloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "videosaver_downloads_total",
+		Help: "Попытки скачивания по платформе, провайдеру и результату.",
+	}, []string{"platform", "provider", "outcome"})
+
+	metricDownloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "videosaver_download_duration_seconds",
+		Help:    "Длительность скачивания по всей цепочке провайдеров.",
+		Buckets: []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 180},
+	}, []string{"platform"})
+
+	metricUploadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "videosaver_upload_duration_seconds",
+		Help:    "Длительность отправки видео в Telegram.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	})
+
+	metricBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "videosaver_bytes_total",
+		Help: "Объём скачанных и отправленных видео в байтах.",
+	}, []string{"direction"})
+
+	metricCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "videosaver_file_cache_hits_total",
+		Help: "Повторные отправки по сохранённому file_id без скачивания.",
+	})
+
+	metricToolFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "videosaver_tool_failures_total",
+		Help: "Ошибки запуска внешних утилит (yt-dlp, ffprobe, ffmpeg).",
+	}, []string{"tool"})
+
+	metricTelegramErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "videosaver_telegram_api_errors_total",
+		Help: "Ошибки Telegram Bot API по методу.",
+	}, []string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		metricDownloads,
+		metricDownloadDuration,
+		metricUploadDuration,
+		metricBytes,
+		metricCacheHits,
+		metricToolFailures,
+		metricTelegramErrors,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "videosaver_queue_length",
+			Help: "Задачи, ожидающие слот скачивания.",
+		}, func() float64 {
+			queued, _ := downloadScheduler.stats()
+			return float64(queued)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "videosaver_running_jobs",
+			Help: "Задачи, занимающие слот скачивания.",
+		}, func() float64 {
+			_, running := downloadScheduler.stats()
+			return float64(running)
+		}),
+	)
+
+	downloader.SetObserver(downloadObserver{})
+}
+
+// startMetricsServer отдаёт метрики Prometheus по адресу addr на пути /metrics
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	slog.Info("Метрики Prometheus доступны", "addr", addr, "path", "/metrics")
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Ошибка HTTP-сервера метрик", "error", err)
+	}
+}
+
+// downloadObserver переводит события downloader в метрики и статистику провайдеров
+type downloadObserver struct{}
+
+func (downloadObserver) ProviderResult(platform downloader.PlatformType, provider string, err error, _ time.Duration) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	metricDownloads.WithLabelValues(string(platform), provider, outcome).Inc()
+	botStats.recordProvider(platform, provider, err)
+}
+
+func (downloadObserver) ToolFailure(tool string) {
+	metricToolFailures.WithLabelValues(tool).Inc()
+}
+
+// instrumentedClient считает ошибки Telegram Bot API. Оборачивает HTTP-клиент бота,
+// поэтому учитываются и запросы tgbotapi, и собственные multipart-загрузки.
+type instrumentedClient struct {
+	inner tgbotapi.HTTPClient
+}
+
+func (c instrumentedClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+
+	resp, err := c.inner.Do(req)
+	if err != nil {
+		// Прерванный при остановке опроса long poll — не ошибка API
+		if req.Context().Err() == nil {
+			metricTelegramErrors.WithLabelValues(method).Inc()
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		metricTelegramErrors.WithLabelValues(method).Inc()
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var apiResp struct {
+		OK bool `json:"ok"`
+	}
+	if json.Unmarshal(body, &apiResp) != nil || !apiResp.OK {
+		metricTelegramErrors.WithLabelValues(method).Inc()
+	}
+	return resp, nil
+}
@@ -0,0 +1,6 @@
+package main
+
+import "goland/VideoSaverBot/downloader"
+
+// noteConversion — преобразование для /note: видеосообщение (кружок) из центра кадра
+var noteConversion = conversion{name: "note", stages: downloader.Pipeline{downloader.VideoNote()}, send: fileVideoNote}
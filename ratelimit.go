@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"goland/VideoSaverBot/i18n"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const limiterStateFile = "limits.json"
+
+// limiter ограничивает частоту запросов (token bucket на пользователя и на чат)
+// и дневные квоты пользователя по количеству загрузок и объёму.
+// Состояние периодически сохраняется на диск, поэтому перезапуск не обнуляет счётчики.
+type limiter struct {
+	mu    sync.Mutex
+	dirty bool
+
+	userRate       int   // запросов в минуту на пользователя, 0 — без ограничения
+	chatRate       int   // запросов в минуту на чат, 0 — без ограничения
+	dailyDownloads int   // загрузок в сутки на пользователя, 0 — без ограничения
+	dailyBytes     int64 // байт в сутки на пользователя, 0 — без ограничения
+
+	exemptUsers map[int64]bool
+	exemptChats map[int64]bool
+
+	state limiterState
+}
+
+type limiterState struct {
+	Users  map[int64]*bucket `json:"users"`
+	Chats  map[int64]*bucket `json:"chats"`
+	Quotas map[int64]*quota  `json:"quotas"`
+}
+
+type bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+type quota struct {
+	Day       string `json:"day"`
+	Downloads int    `json:"downloads"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// limitError описывает отказ лимитера: слишком частые запросы или исчерпанная квота
+type limitError struct {
+	quota bool
+	wait  time.Duration
+}
+
+func (e *limitError) Error() string {
+	if e.quota {
+		return fmt.Sprintf("дневной лимит исчерпан, сброс через %v", e.wait)
+	}
+	return fmt.Sprintf("слишком много запросов, повторите через %v", e.wait)
+}
+
+func newLimiter() *limiter {
+	l := &limiter{
+		exemptUsers: map[int64]bool{},
+		exemptChats: map[int64]bool{},
+	}
+
+	if err := loadJSON(limiterStateFile, &l.state); err != nil {
+		slog.Error("Не удалось загрузить состояние лимитов", "error", err)
+	}
+	if l.state.Users == nil {
+		l.state.Users = map[int64]*bucket{}
+	}
+	if l.state.Chats == nil {
+		l.state.Chats = map[int64]*bucket{}
+	}
+	if l.state.Quotas == nil {
+		l.state.Quotas = map[int64]*quota{}
+	}
+	return l
+}
+
+// configure задаёт ограничения; вызывается при запуске и перезагрузке конфигурации
+func (l *limiter) configure(userRate, chatRate, dailyDownloads int, dailyBytes int64, exemptUsers, exemptChats map[int64]bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.userRate = userRate
+	l.chatRate = chatRate
+	l.dailyDownloads = dailyDownloads
+	l.dailyBytes = dailyBytes
+	l.exemptUsers = exemptUsers
+	l.exemptChats = exemptChats
+}
+
+// allow проверяет лимиты для n новых загрузок и при успехе списывает токены.
+// Возвращает *limitError, если запрос нужно отклонить.
+func (l *limiter) allow(userID, chatID int64, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.exemptUsers[userID] || l.exemptChats[chatID] {
+		return nil
+	}
+
+	now := time.Now()
+
+	if q := l.quotaLocked(userID, now); q != nil {
+		if (l.dailyDownloads > 0 && q.Downloads+n > l.dailyDownloads) ||
+			(l.dailyBytes > 0 && q.Bytes >= l.dailyBytes) {
+			return &limitError{quota: true, wait: nextDay(now).Sub(now).Round(time.Minute)}
+		}
+	}
+
+	buckets := []struct {
+		bucket *bucket
+		rate   int
+	}{
+		{refill(l.state.Users, userID, l.userRate, now), l.userRate},
+		{refill(l.state.Chats, chatID, l.chatRate, now), l.chatRate},
+	}
+	for _, b := range buckets {
+		if b.bucket == nil {
+			continue
+		}
+		if need := cost(n, b.rate); b.bucket.Tokens < need {
+			wait := time.Duration((need - b.bucket.Tokens) / float64(b.rate) * float64(time.Minute))
+			return &limitError{wait: wait.Round(time.Second) + time.Second}
+		}
+	}
+
+	for _, b := range buckets {
+		if b.bucket != nil {
+			b.bucket.Tokens -= cost(n, b.rate)
+		}
+	}
+	l.dirty = true
+	return nil
+}
+
+// cost — сколько токенов списать за n загрузок; пакет больше ёмкости
+// ведра требует полного ведра, иначе он никогда не прошёл бы проверку
+func cost(n, rate int) float64 {
+	if n > rate {
+		n = rate
+	}
+	return float64(n)
+}
+
+// record учитывает успешную загрузку в дневной квоте пользователя
+func (l *limiter) record(userID int64, size int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.exemptUsers[userID] {
+		return
+	}
+
+	q := l.quotaLocked(userID, time.Now())
+	if q == nil {
+		return
+	}
+	q.Downloads++
+	q.Bytes += size
+	l.dirty = true
+}
+
+// quotaLocked возвращает квоту пользователя за текущие сутки (UTC)
+// или nil, если дневные ограничения отключены
+func (l *limiter) quotaLocked(userID int64, now time.Time) *quota {
+	if l.dailyDownloads <= 0 && l.dailyBytes <= 0 {
+		return nil
+	}
+
+	day := now.UTC().Format("2006-01-02")
+	q := l.state.Quotas[userID]
+	if q == nil || q.Day != day {
+		q = &quota{Day: day}
+		l.state.Quotas[userID] = q
+	}
+	return q
+}
+
+// refill пополняет ведро ключа по прошедшему времени; rate — токенов в минуту,
+// он же ёмкость ведра. При rate <= 0 ограничение отключено и возвращается nil.
+func refill(buckets map[int64]*bucket, key int64, rate int, now time.Time) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	b := buckets[key]
+	if b == nil {
+		b = &bucket{Tokens: float64(rate), Updated: now}
+		buckets[key] = b
+		return b
+	}
+
+	b.Tokens += now.Sub(b.Updated).Minutes() * float64(rate)
+	if b.Tokens > float64(rate) {
+		b.Tokens = float64(rate)
+	}
+	b.Updated = now
+	return b
+}
+
+// flush сохраняет состояние, если оно менялось, отбрасывая полные вёдра и устаревшие квоты
+func (l *limiter) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return
+	}
+
+	now := time.Now()
+	for _, buckets := range []struct {
+		m    map[int64]*bucket
+		rate int
+	}{{l.state.Users, l.userRate}, {l.state.Chats, l.chatRate}} {
+		for key, b := range buckets.m {
+			if b.Tokens+now.Sub(b.Updated).Minutes()*float64(buckets.rate) >= float64(buckets.rate) {
+				delete(buckets.m, key)
+			}
+		}
+	}
+
+	today := now.UTC().Format("2006-01-02")
+	for key, q := range l.state.Quotas {
+		if q.Day != today {
+			delete(l.state.Quotas, key)
+		}
+	}
+
+	if err := saveJSON(limiterStateFile, &l.state); err != nil {
+		slog.Error("Не удалось сохранить состояние лимитов", "error", err)
+		return
+	}
+	l.dirty = false
+}
+
+// startPeriodicFlush сохраняет состояние лимитов раз в минуту
+func (l *limiter) startPeriodicFlush() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.flush()
+	}
+}
+
+// nextDay возвращает начало следующих суток по UTC
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// limitMessage формирует понятное пользователю сообщение об отказе лимитера
+func limitMessage(lang i18n.Lang, err *limitError) string {
+	if err.quota {
+		return lang.T("limit.quota", nextDay(time.Now()).Format("15:04"), formatWait(lang, err.wait))
+	}
+	return lang.T("limit.rate", formatWait(lang, err.wait))
+}
+
+func formatWait(lang i18n.Lang, d time.Duration) string {
+	if d < time.Minute {
+		return lang.T("wait.seconds", int(d.Seconds()))
+	}
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return lang.T("wait.minutes", minutes)
+	}
+	return lang.T("wait.hours", hours, minutes)
+}
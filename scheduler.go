@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// errQueueDropped — задача снята с очереди: пользователь или чат заблокирован
+// либо включён режим обслуживания
+var errQueueDropped = errors.New("задача снята с очереди")
+
+// scheduler распределяет слоты скачивания между чатами и пользователями.
+// Задачи приоритетных пользователей выдаются первыми, остальные — по кругу:
+// сначала между чатами, внутри чата — между пользователями. Так один
+// активный групповой чат не может занять все слоты.
+type scheduler struct {
+	mu       sync.Mutex
+	slots    int
+	running  int
+	priority []*ticket
+	chats    []*chatQueue // кольцо: первый элемент получит следующий слот
+}
+
+type chatQueue struct {
+	chatID int64
+	users  []*userQueue // кольцо пользователей внутри чата
+}
+
+type userQueue struct {
+	userID  int64
+	tickets []*ticket
+}
+
+// ticket — место задачи в очереди планировщика
+type ticket struct {
+	userID   int64
+	chatID   int64
+	ready    chan struct{}
+	dropped  chan struct{} // закрывается, если задачу сняли с очереди через drop
+	position chan int      // последняя известная позиция, буфер 1
+}
+
+func newScheduler(slots int) *scheduler {
+	if slots < 1 {
+		slots = 1
+	}
+	return &scheduler{slots: slots}
+}
+
+// acquire ставит задачу в очередь и блокирует до выделения слота.
+// onPosition вызывается при каждом изменении позиции задачи в очереди
+// (позиции считаются с 1); если слот свободен сразу, не вызывается ни разу.
+// При отмене ctx задача убирается из очереди и возвращается ошибка контекста,
+// при снятии с очереди через drop — errQueueDropped. После успешного
+// выделения слот нужно вернуть через release.
+func (s *scheduler) acquire(ctx context.Context, userID, chatID int64, priority bool, onPosition func(int)) error {
+	t := &ticket{
+		userID:   userID,
+		chatID:   chatID,
+		ready:    make(chan struct{}),
+		dropped:  make(chan struct{}),
+		position: make(chan int, 1),
+	}
+
+	s.mu.Lock()
+	s.enqueueLocked(t, priority)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-t.ready:
+			return nil
+		case <-t.dropped:
+			return errQueueDropped
+		case pos := <-t.position:
+			if onPosition != nil {
+				onPosition(pos)
+			}
+		case <-ctx.Done():
+			s.cancel(t)
+			return ctx.Err()
+		}
+	}
+}
+
+// cancel убирает задачу из очереди. Если слот успели выдать, он возвращается.
+func (s *scheduler) cancel(t *ticket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.removeLocked(t) {
+		s.dispatchLocked()
+		return
+	}
+	select {
+	case <-t.ready:
+		s.running--
+		s.dispatchLocked()
+	default:
+	}
+}
+
+// drop снимает с очереди ожидающие задачи, для которых match возвращает true,
+// и возвращает их количество. Выполняющиеся задачи не затрагиваются.
+func (s *scheduler) drop(match func(userID, chatID int64) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dropped []*ticket
+	for _, t := range s.snapshotLocked().drain() {
+		if match(t.userID, t.chatID) {
+			dropped = append(dropped, t)
+		}
+	}
+	for _, t := range dropped {
+		s.removeLocked(t)
+		close(t.dropped)
+	}
+	if len(dropped) > 0 {
+		s.dispatchLocked()
+	}
+	return len(dropped)
+}
+
+// release возвращает слот и передаёт его следующей задаче
+func (s *scheduler) release() {
+	s.mu.Lock()
+	s.running--
+	s.dispatchLocked()
+	s.mu.Unlock()
+}
+
+// stats возвращает количество ожидающих и выполняющихся задач
+func (s *scheduler) stats() (queued, running int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queued = len(s.priority)
+	for _, c := range s.chats {
+		for _, u := range c.users {
+			queued += len(u.tickets)
+		}
+	}
+	return queued, s.running
+}
+
+func (s *scheduler) enqueueLocked(t *ticket, priority bool) {
+	if priority {
+		s.priority = append(s.priority, t)
+		return
+	}
+
+	var chat *chatQueue
+	for _, c := range s.chats {
+		if c.chatID == t.chatID {
+			chat = c
+			break
+		}
+	}
+	if chat == nil {
+		chat = &chatQueue{chatID: t.chatID}
+		s.chats = append(s.chats, chat)
+	}
+
+	for _, u := range chat.users {
+		if u.userID == t.userID {
+			u.tickets = append(u.tickets, t)
+			return
+		}
+	}
+	chat.users = append(chat.users, &userQueue{userID: t.userID, tickets: []*ticket{t}})
+}
+
+// dispatchLocked выдаёт свободные слоты и рассылает ожидающим новые позиции
+func (s *scheduler) dispatchLocked() {
+	for s.running < s.slots {
+		t := s.popLocked()
+		if t == nil {
+			break
+		}
+		s.running++
+		close(t.ready)
+	}
+
+	for i, t := range s.snapshotLocked().drain() {
+		select {
+		case <-t.position:
+		default:
+		}
+		t.position <- i + 1
+	}
+}
+
+// removeLocked убирает ожидающую задачу из очередей, сохраняя порядок остальных.
+// Возвращает false, если задачи в очереди нет: ей уже выдан слот или её сняли.
+func (s *scheduler) removeLocked(t *ticket) bool {
+	if i := slices.Index(s.priority, t); i >= 0 {
+		s.priority = slices.Delete(s.priority, i, i+1)
+		return true
+	}
+
+	for ci, chat := range s.chats {
+		if chat.chatID != t.chatID {
+			continue
+		}
+		for ui, user := range chat.users {
+			i := slices.Index(user.tickets, t)
+			if i < 0 {
+				continue
+			}
+			user.tickets = slices.Delete(user.tickets, i, i+1)
+			if len(user.tickets) == 0 {
+				chat.users = slices.Delete(chat.users, ui, ui+1)
+			}
+			if len(chat.users) == 0 {
+				s.chats = slices.Delete(s.chats, ci, ci+1)
+			}
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func (s *scheduler) popLocked() *ticket {
+	if len(s.priority) > 0 {
+		t := s.priority[0]
+		s.priority = s.priority[1:]
+		return t
+	}
+	if len(s.chats) == 0 {
+		return nil
+	}
+
+	chat := s.chats[0]
+	user := chat.users[0]
+	t := user.tickets[0]
+	user.tickets = user.tickets[1:]
+
+	chat.users = chat.users[1:]
+	if len(user.tickets) > 0 {
+		chat.users = append(chat.users, user)
+	}
+
+	s.chats = s.chats[1:]
+	if len(chat.users) > 0 {
+		s.chats = append(s.chats, chat)
+	}
+	return t
+}
+
+// snapshotLocked копирует очереди, чтобы вычислить порядок выдачи без изменения состояния
+func (s *scheduler) snapshotLocked() *scheduler {
+	cp := &scheduler{priority: append([]*ticket(nil), s.priority...)}
+	for _, c := range s.chats {
+		chat := &chatQueue{chatID: c.chatID}
+		for _, u := range c.users {
+			chat.users = append(chat.users, &userQueue{
+				userID:  u.userID,
+				tickets: append([]*ticket(nil), u.tickets...),
+			})
+		}
+		cp.chats = append(cp.chats, chat)
+	}
+	return cp
+}
+
+// drain извлекает все задачи в порядке, в котором они получат слоты
+func (s *scheduler) drain() []*ticket {
+	var order []*ticket
+	for t := s.popLocked(); t != nil; t = s.popLocked() {
+		order = append(order, t)
+	}
+	return order
+}
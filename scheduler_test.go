@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitQueued ждёт, пока в очереди планировщика окажется n задач
+func waitQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if queued, _ := s.stats(); queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			queued, _ := s.stats()
+			t.Fatalf("в очереди %d задач, ожидалось %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerCancelQueued(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.Background(), 1, 1, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() { cancelled <- s.acquire(ctx, 2, 2, false, nil) }()
+	waitQueued(t, s, 1)
+
+	next := make(chan error, 1)
+	go func() { next <- s.acquire(context.Background(), 3, 3, false, nil) }()
+	waitQueued(t, s, 2)
+
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire = %v, want context.Canceled", err)
+	}
+	waitQueued(t, s, 1)
+
+	// Освободившийся слот достаётся оставшейся задаче, а не отменённой
+	s.release()
+	if err := <-next; err != nil {
+		t.Fatalf("acquire = %v", err)
+	}
+	if queued, running := s.stats(); queued != 0 || running != 1 {
+		t.Errorf("stats = %d queued, %d running, want 0, 1", queued, running)
+	}
+}
+
+func TestSchedulerCancelKeepsRingOrder(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.Background(), 1, 1, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Два пользователя одного чата и пользователь другого чата
+	contexts := map[int64]context.CancelFunc{}
+	done := map[int64]chan error{}
+	for _, job := range []struct{ userID, chatID int64 }{{10, 100}, {11, 100}, {20, 200}} {
+		ctx, cancel := context.WithCancel(context.Background())
+		contexts[job.userID] = cancel
+		result := make(chan error, 1)
+		done[job.userID] = result
+		go func(userID, chatID int64) { result <- s.acquire(ctx, userID, chatID, false, nil) }(job.userID, job.chatID)
+		waitQueued(t, s, len(done))
+	}
+
+	contexts[10]()
+	<-done[10]
+
+	s.release()
+	if err := <-done[11]; err != nil {
+		t.Fatalf("acquire = %v, want slot for the next user of the chat", err)
+	}
+	s.release()
+	if err := <-done[20]; err != nil {
+		t.Fatalf("acquire = %v", err)
+	}
+	contexts[11]()
+	contexts[20]()
+}
+
+func TestSchedulerDrop(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.Background(), 1, 1, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	banned := make(chan error, 1)
+	kept := make(chan error, 1)
+	go func() { banned <- s.acquire(context.Background(), 2, 2, false, nil) }()
+	waitQueued(t, s, 1)
+	go func() { kept <- s.acquire(context.Background(), 3, 3, true, nil) }()
+	waitQueued(t, s, 2)
+
+	if n := s.drop(func(userID, _ int64) bool { return userID == 2 }); n != 1 {
+		t.Errorf("drop = %d, want 1", n)
+	}
+	if err := <-banned; !errors.Is(err, errQueueDropped) {
+		t.Fatalf("acquire = %v, want errQueueDropped", err)
+	}
+
+	s.release()
+	if err := <-kept; err != nil {
+		t.Fatalf("acquire = %v", err)
+	}
+	if queued, running := s.stats(); queued != 0 || running != 1 {
+		t.Errorf("stats = %d queued, %d running, want 0, 1", queued, running)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"html"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	statsStateFile = "stats.json"
+
+	statsRetentionDays = 90   // сколько суток хранится статистика
+	maxLatencySamples  = 1000 // выборка длительностей на платформу в сутки для медианы
+	statsWindowDays    = 7    // период для сводок /stats providers и /stats errors
+)
+
+// statsStore накапливает статистику загрузок по суткам (UTC) и периодически
+// сохраняет её на диск, чтобы она переживала перезапуски
+type statsStore struct {
+	mu    sync.Mutex
+	dirty bool
+	state statsState
+}
+
+type statsState struct {
+	Days map[string]*dayStats `json:"days"`
+}
+
+type dayStats struct {
+	Platforms map[string]*platformStats `json:"platforms"`
+	Providers map[string]*outcomeCount  `json:"providers"` // ключ — "платформа/провайдер"
+	Errors    map[string]int            `json:"errors"`    // ключ — причина из classifyError
+	Users     map[int64]bool            `json:"users"`
+	Chats     map[int64]bool            `json:"chats"`
+}
+
+type platformStats struct {
+	Success   int       `json:"success"`
+	Failure   int       `json:"failure"`
+	Cached    int       `json:"cached"`
+	Latencies []float64 `json:"latencies"` // секунды
+}
+
+type outcomeCount struct {
+	Success int `json:"success"`
+	Failure int `json:"failure"`
+}
+
+// errorReasons сопоставляет ошибки downloader с причинами для статистики
+var errorReasons = []struct {
+	err    error
+	reason string // подпись причины в /stats — ключ stats.reason.<reason> каталога i18n
+}{
+	{downloader.ErrTimeout, "timeout"},
+	{downloader.ErrPrivate, "private"},
+	{downloader.ErrAgeRestricted, "age_restricted"},
+	{downloader.ErrGeoBlocked, "geo_blocked"},
+	{downloader.ErrUnavailable, "unavailable"},
+	{downloader.ErrTooLarge, "too_large"},
+	{downloader.ErrAuthRequired, "auth_required"},
+	{downloader.ErrToolUnavailable, "tool_unavailable"},
+	{downloader.ErrVideoNotFound, "not_found"},
+	{downloader.ErrNotMedia, "not_media"},
+	{downloader.ErrConversion, "conversion"},
+	{downloader.ErrProvidersDisabled, "disabled"},
+	{downloader.ErrUnsupported, "unsupported"},
+	{downloader.ErrStorage, "storage"},
+	{downloader.ErrProviderFailed, "service"},
+	{errTooLong, "too_long"},
+	{errFileTooBig, "file_too_big"},
+	{errSend, "send"},
+}
+
+// classifyError возвращает причину ошибки для статистики
+func classifyError(err error) string {
+	for _, r := range errorReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "other"
+}
+
+// reasonLabel возвращает подпись причины ошибки на языке администратора
+func reasonLabel(lang i18n.Lang, reason string) string {
+	if i18n.Has("stats.reason." + reason) {
+		return lang.T("stats.reason." + reason)
+	}
+	return lang.T("stats.reason.other")
+}
+
+func newStatsStore() *statsStore {
+	s := &statsStore{}
+	if err := loadJSON(statsStateFile, &s.state); err != nil {
+		slog.Error("Не удалось загрузить статистику", "error", err)
+	}
+	if s.state.Days == nil {
+		s.state.Days = map[string]*dayStats{}
+	}
+	return s
+}
+
+// recordJob учитывает результат скачивания ссылки; latency — время работы
+// цепочки провайдеров без ожидания в очереди
+func (s *statsStore) recordJob(platform downloader.PlatformType, userID, chatID int64, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := s.todayLocked()
+	p := day.platform(platform)
+	if err != nil {
+		p.Failure++
+		day.Errors[classifyError(err)]++
+	} else {
+		p.Success++
+		p.addLatency(latency.Seconds())
+	}
+	day.Users[userID] = true
+	day.Chats[chatID] = true
+	s.dirty = true
+}
+
+// recordCached учитывает отправку по сохранённому file_id без скачивания
+func (s *statsStore) recordCached(platform downloader.PlatformType, userID, chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := s.todayLocked()
+	day.platform(platform).Cached++
+	day.Users[userID] = true
+	day.Chats[chatID] = true
+	s.dirty = true
+}
+
+// recordProvider учитывает результат отдельного провайдера в цепочке
+func (s *statsStore) recordProvider(platform downloader.PlatformType, provider string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := s.todayLocked()
+	key := string(platform) + "/" + provider
+	c := day.Providers[key]
+	if c == nil {
+		c = &outcomeCount{}
+		day.Providers[key] = c
+	}
+	if err != nil {
+		c.Failure++
+	} else {
+		c.Success++
+	}
+	s.dirty = true
+}
+
+func (s *statsStore) todayLocked() *dayStats {
+	key := time.Now().UTC().Format("2006-01-02")
+	day := s.state.Days[key]
+	if day == nil {
+		day = &dayStats{}
+		s.state.Days[key] = day
+	}
+	// Карты могут отсутствовать в файле, сохранённом без данных
+	if day.Platforms == nil {
+		day.Platforms = map[string]*platformStats{}
+	}
+	if day.Providers == nil {
+		day.Providers = map[string]*outcomeCount{}
+	}
+	if day.Errors == nil {
+		day.Errors = map[string]int{}
+	}
+	if day.Users == nil {
+		day.Users = map[int64]bool{}
+	}
+	if day.Chats == nil {
+		day.Chats = map[int64]bool{}
+	}
+	return day
+}
+
+func (d *dayStats) platform(platform downloader.PlatformType) *platformStats {
+	name := string(platform)
+	if name == "" {
+		name = "unknown"
+	}
+	p := d.Platforms[name]
+	if p == nil {
+		p = &platformStats{}
+		d.Platforms[name] = p
+	}
+	return p
+}
+
+// addLatency добавляет длительность в выборку; когда выборка заполнена,
+// случайный элемент заменяется (reservoir sampling), чтобы медиана оставалась честной
+func (p *platformStats) addLatency(seconds float64) {
+	if len(p.Latencies) < maxLatencySamples {
+		p.Latencies = append(p.Latencies, seconds)
+		return
+	}
+	if i := rand.Intn(p.Success); i < maxLatencySamples {
+		p.Latencies[i] = seconds
+	}
+}
+
+// flush сохраняет статистику, если она менялась, и удаляет сутки старше statsRetentionDays
+func (s *statsStore) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+
+	oldest := time.Now().UTC().AddDate(0, 0, -statsRetentionDays).Format("2006-01-02")
+	for key := range s.state.Days {
+		if key < oldest {
+			delete(s.state.Days, key)
+		}
+	}
+
+	if err := saveJSON(statsStateFile, &s.state); err != nil {
+		slog.Error("Не удалось сохранить статистику", "error", err)
+		return
+	}
+	s.dirty = false
+}
+
+// startPeriodicFlush сохраняет статистику раз в минуту
+func (s *statsStore) startPeriodicFlush() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.flush()
+	}
+}
+
+// daysLocked возвращает статистику за последние n суток, начиная с сегодняшних
+func (s *statsStore) daysLocked(n int) []*dayStats {
+	var days []*dayStats
+	now := time.Now().UTC()
+	for i := 0; i < n; i++ {
+		if day := s.state.Days[now.AddDate(0, 0, -i).Format("2006-01-02")]; day != nil {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// statsSummary — агрегат статистики за несколько суток
+type statsSummary struct {
+	success, failure, cached int
+	users, chats             int
+	latencies                []float64
+}
+
+func summarize(days []*dayStats) statsSummary {
+	var sum statsSummary
+	users := map[int64]bool{}
+	chats := map[int64]bool{}
+	for _, day := range days {
+		for _, p := range day.Platforms {
+			sum.success += p.Success
+			sum.failure += p.Failure
+			sum.cached += p.Cached
+			sum.latencies = append(sum.latencies, p.Latencies...)
+		}
+		for id := range day.Users {
+			users[id] = true
+		}
+		for id := range day.Chats {
+			chats[id] = true
+		}
+	}
+	sum.users = len(users)
+	sum.chats = len(chats)
+	return sum
+}
+
+// median возвращает медиану выборки в секундах
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func successRate(success, failure int) string {
+	if success+failure == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.0f%%", float64(success)*100/float64(success+failure))
+}
+
+// sendStats отвечает на /stats администратора. Подкоманды: today, providers, errors.
+func sendStats(bot *tgbotapi.BotAPI, lang i18n.Lang, chatID int64, args string) {
+	var text string
+	switch strings.TrimSpace(args) {
+	case "":
+		text = botStats.overview(lang)
+	case "today":
+		text = botStats.today(lang)
+	case "providers":
+		text = botStats.providers(lang)
+	case "errors":
+		text = botStats.failures(lang)
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, lang.T("stats.usage")))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	if _, err := bot.Send(msg); err != nil {
+		slog.Error("Не удалось отправить статистику", "error", err)
+	}
+}
+
+// overview — общая сводка: текущая нагрузка, сегодня, 7 суток и весь период хранения
+func (s *statsStore) overview(lang i18n.Lang) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queued, running := downloadScheduler.stats()
+
+	var b strings.Builder
+	b.WriteString(lang.T("stats.title", time.Since(statStart).Round(time.Minute), queued, running))
+	b.WriteString("\n\n<pre>")
+	fmt.Fprintf(&b, "%-10s %6s %6s %6s %5s %6s %5s\n", lang.T("stats.period"), "OK", lang.T("stats.failures"),
+		lang.T("stats.cached"), lang.T("stats.success"), lang.T("stats.users"), lang.T("stats.chats"))
+	for _, period := range []struct {
+		name string
+		days int
+	}{{lang.T("stats.today"), 1}, {lang.T("stats.days", statsWindowDays), statsWindowDays}, {lang.T("stats.days", statsRetentionDays), statsRetentionDays}} {
+		sum := summarize(s.daysLocked(period.days))
+		fmt.Fprintf(&b, "%-10s %6d %6d %6d %5s %6d %5d\n",
+			period.name, sum.success, sum.failure, sum.cached,
+			successRate(sum.success, sum.failure), sum.users, sum.chats)
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// today — разбивка сегодняшних загрузок по платформам с медианной длительностью
+func (s *statsStore) today(lang i18n.Lang) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days := s.daysLocked(1)
+	if len(days) == 0 {
+		return lang.T("stats.today.empty")
+	}
+	day := days[0]
+
+	names := make([]string, 0, len(day.Platforms))
+	for name := range day.Platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(lang.T("stats.today.title"))
+	b.WriteString("\n<pre>")
+	fmt.Fprintf(&b, "%-10s %5s %6s %5s %7s\n", lang.T("stats.platform"), "OK", lang.T("stats.failures"),
+		lang.T("stats.cached"), lang.T("stats.median"))
+	seconds := lang.T("stats.seconds")
+	for _, name := range names {
+		p := day.Platforms[name]
+		fmt.Fprintf(&b, "%-10s %5d %6d %5d %6.1f%s\n",
+			html.EscapeString(name), p.Success, p.Failure, p.Cached, median(p.Latencies), seconds)
+	}
+	sum := summarize(days)
+	fmt.Fprintf(&b, "%-10s %5d %6d %5d %6.1f%s\n", lang.T("stats.total"), sum.success, sum.failure, sum.cached,
+		median(sum.latencies), seconds)
+	b.WriteString("</pre>")
+	b.WriteString(lang.T("stats.today.unique", sum.users, sum.chats))
+	return b.String()
+}
+
+// providers — успешность провайдеров за statsWindowDays суток
+func (s *statsStore) providers(lang i18n.Lang) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totals := map[string]*outcomeCount{}
+	for _, day := range s.daysLocked(statsWindowDays) {
+		for key, c := range day.Providers {
+			t := totals[key]
+			if t == nil {
+				t = &outcomeCount{}
+				totals[key] = t
+			}
+			t.Success += c.Success
+			t.Failure += c.Failure
+		}
+	}
+	if len(totals) == 0 {
+		return lang.T("stats.providers.empty", statsWindowDays)
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(lang.T("stats.providers.title", statsWindowDays))
+	b.WriteString("\n<pre>")
+	fmt.Fprintf(&b, "%-22s %5s %6s %5s\n", lang.T("stats.provider"), "OK", lang.T("stats.failures"), lang.T("stats.success"))
+	for _, key := range keys {
+		c := totals[key]
+		fmt.Fprintf(&b, "%-22s %5d %6d %5s\n",
+			html.EscapeString(key), c.Success, c.Failure, successRate(c.Success, c.Failure))
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// failures — причины неудачных загрузок за statsWindowDays суток
+func (s *statsStore) failures(lang i18n.Lang) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totals := map[string]int{}
+	total := 0
+	for _, day := range s.daysLocked(statsWindowDays) {
+		for reason, n := range day.Errors {
+			totals[reason] += n
+			total += n
+		}
+	}
+	if total == 0 {
+		return lang.T("stats.errors.empty", statsWindowDays)
+	}
+
+	reasons := make([]string, 0, len(totals))
+	for reason := range totals {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if totals[reasons[i]] != totals[reasons[j]] {
+			return totals[reasons[i]] > totals[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	var b strings.Builder
+	b.WriteString(lang.T("stats.errors.title", statsWindowDays))
+	b.WriteString("\n<pre>")
+	fmt.Fprintf(&b, "%-24s %6s %5s\n", lang.T("stats.reason"), lang.T("stats.count"), lang.T("stats.share"))
+	for _, reason := range reasons {
+		n := totals[reason]
+		fmt.Fprintf(&b, "%-24s %6d %4.0f%%\n", reasonLabel(lang, reason), n, float64(n)*100/float64(total))
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
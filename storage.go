@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// dataDir — каталог для состояния, которое должно переживать перезапуски
+var dataDir = "data"
+
+// loadJSON читает состояние из файла в каталоге данных.
+// Отсутствующий файл не считается ошибкой: v остаётся без изменений.
+func loadJSON(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(dataDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveJSON атомарно записывает состояние: сначала во временный файл, затем rename
+func saveJSON(name string, v interface{}) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dataDir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
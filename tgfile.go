@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"goland/VideoSaverBot/downloader"
+	"goland/VideoSaverBot/i18n"
+	"goland/VideoSaverBot/logging"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramFileLimit — Bot API отдаёт через getFile файлы не больше 20 МБ
+const telegramFileLimit = 20 * 1024 * 1024
+
+var errFileTooBig = errors.New("файл больше 20 МБ, Bot API не позволяет его скачать")
+
+// telegramVideo — видео, отправленное в Telegram, которое команда преобразует без ссылки
+type telegramVideo struct {
+	fileID string
+	size   int
+}
+
+// messageVideo возвращает видео из сообщения: видео, анимацию, видеосообщение
+// или документ с видео
+func messageVideo(message *tgbotapi.Message) (telegramVideo, bool) {
+	switch {
+	case message.Video != nil:
+		return telegramVideo{message.Video.FileID, message.Video.FileSize}, true
+	case message.Animation != nil:
+		return telegramVideo{message.Animation.FileID, message.Animation.FileSize}, true
+	case message.VideoNote != nil:
+		return telegramVideo{message.VideoNote.FileID, message.VideoNote.FileSize}, true
+	case message.Document != nil && strings.HasPrefix(message.Document.MimeType, "video/"):
+		return telegramVideo{message.Document.FileID, message.Document.FileSize}, true
+	}
+	return telegramVideo{}, false
+}
+
+// downloadTelegramVideo скачивает видео из Telegram во временный каталог пользователя
+func downloadTelegramVideo(ctx context.Context, bot *tgbotapi.BotAPI, video telegramVideo, userID int64) (*downloader.Media, error) {
+	if video.size > telegramFileLimit {
+		return nil, errFileTooBig
+	}
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().Downloads.Timeout)
+	defer cancel()
+
+	fileURL, err := bot.GetFileDirectURL(video.fileID)
+	if err != nil {
+		return nil, err
+	}
+	return downloader.DownloadFile(ctx, fileURL, userID)
+}
+
+// processTelegramVideo скачивает видео из сообщения, на которое ответили командой,
+// преобразует его и отправляет. Ограничения частоты и число загрузок проверяет вызывающий код.
+func processTelegramVideo(ctx context.Context, bot *tgbotapi.BotAPI, target delivery, userID int64, video telegramVideo) {
+	lang := i18n.From(ctx)
+	logger := logging.From(ctx)
+
+	processingMsg, _ := target.sendText(bot, lang.T("processing.file"))
+
+	if err := waitForSlot(ctx, bot, target, userID); err != nil {
+		dropQueuedJob(ctx, bot, target, processingMsg.MessageID, err)
+		return
+	}
+	media, err := downloadTelegramVideo(ctx, bot, video, userID)
+	downloadScheduler.release()
+	if err == nil {
+		err = target.prepare(ctx, media)
+	}
+
+	if err != nil {
+		logger.Error("Ошибка обработки видео из Telegram", "error", err)
+		target.sendText(bot, errorText(lang, err))
+		go deleteMessageAfterDelay(bot, target.chatID, processingMsg.MessageID, 10)
+		return
+	}
+
+	recordDownload(userID, media.Path)
+	sendVideo(ctx, bot, target, "", media, processingMsg.MessageID)
+	go cleanupOldFiles(userID)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// botUpdate — обновление Telegram вместе с полями, которых нет в tgbotapi
+type botUpdate struct {
+	tgbotapi.Update
+
+	// TopicID — message_thread_id темы форума, в которой пришло сообщение; 0 — вне темы
+	TopicID int
+}
+
+func (u *botUpdate) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Update); err != nil {
+		return err
+	}
+
+	var extra struct {
+		Message *struct {
+			MessageThreadID int  `json:"message_thread_id"`
+			IsTopicMessage  bool `json:"is_topic_message"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+	// В обычных супергруппах message_thread_id есть и у ответов, но отправлять
+	// с ним можно только в темы форума
+	if extra.Message != nil && extra.Message.IsTopicMessage {
+		u.TopicID = extra.Message.MessageThreadID
+	}
+	return nil
+}
+
+// pollUpdates получает обновления через getUpdates, как GetUpdatesChan из tgbotapi,
+// но сохраняет поля тем форума. Возвращённая функция останавливает получение,
+// прерывая текущий long poll, дожидается выхода и возвращает offset, с которого
+// следующий вызов pollUpdates продолжит без потерь и повторов.
+func pollUpdates(bot *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) (<-chan botUpdate, func() int) {
+	// Канал без буфера: переданное обновление уже получено обработчиком,
+	// и после остановки в канале не остаётся обновлений, за которыми сдвинут offset
+	updates := make(chan botUpdate)
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+
+	// retry ждёт перед повторным запросом; false — опрос остановлен
+	retry := func() bool {
+		select {
+		case <-time.After(3 * time.Second):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(exited)
+		defer close(updates)
+		for ctx.Err() == nil {
+			params := tgbotapi.Params{}
+			params.AddNonZero("offset", config.Offset)
+			params.AddNonZero("limit", config.Limit)
+			params.AddNonZero("timeout", config.Timeout)
+			if err := params.AddInterface("allowed_updates", config.AllowedUpdates); err != nil {
+				slog.Error("Некорректный список allowed_updates", "error", err)
+				return
+			}
+
+			batch, err := getUpdates(ctx, bot, params)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Не удалось получить обновления, повтор через 3 секунды", "error", err)
+				if !retry() {
+					return
+				}
+				continue
+			}
+
+			// offset сдвигается только после передачи обновления, чтобы обновления,
+			// не переданные до остановки, получил следующий опрос
+			for _, update := range batch {
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+				if update.UpdateID >= config.Offset {
+					config.Offset = update.UpdateID + 1
+				}
+			}
+		}
+	}()
+
+	stop := func() int {
+		cancel()
+		<-exited
+		return config.Offset
+	}
+	return updates, stop
+}
+
+// getUpdates выполняет запрос getUpdates с контекстом. MakeRequest из tgbotapi
+// контекст не принимает, и остановка опроса ждала бы окончания long poll.
+func getUpdates(ctx context.Context, bot *tgbotapi.BotAPI, params tgbotapi.Params) ([]botUpdate, error) {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+
+	apiURL := fmt.Sprintf(tgbotapi.APIEndpoint, bot.Token, "getUpdates")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := bot.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp tgbotapi.APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, err
+	}
+	if !apiResp.Ok {
+		apiErr := &tgbotapi.Error{Code: apiResp.ErrorCode, Message: apiResp.Description}
+		if apiResp.Parameters != nil {
+			apiErr.ResponseParameters = *apiResp.Parameters
+		}
+		return nil, apiErr
+	}
+
+	var batch []botUpdate
+	if err := json.Unmarshal(apiResp.Result, &batch); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать обновления: %w", err)
+	}
+	return batch, nil
+}
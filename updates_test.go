@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// longPollClient отвечает на первый getUpdates пакетом обновлений,
+// а следующие запросы держит до отмены, как long poll без новых обновлений
+type longPollClient struct {
+	offsets chan string // параметр offset каждого запроса
+}
+
+func (c longPollClient) Do(req *http.Request) (*http.Response, error) {
+	offset := req.FormValue("offset")
+	c.offsets <- offset
+	if offset == "" {
+		body := `{"ok":true,"result":[{"update_id":7,"message":{"message_id":1,"chat":{"id":1,"type":"private"}}},{"update_id":8}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestPollUpdatesStop(t *testing.T) {
+	client := longPollClient{offsets: make(chan string, 10)}
+	bot := &tgbotapi.BotAPI{Token: "123:test", Client: client}
+
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = 30
+	updates, stop := pollUpdates(bot, config)
+
+	if update := <-updates; update.UpdateID != 7 {
+		t.Fatalf("update_id = %d, want 7", update.UpdateID)
+	}
+	<-client.offsets
+
+	// Второе обновление не забрано: следующий опрос должен получить его снова
+	if offset := stopWithin(t, stop); offset != 8 {
+		t.Errorf("offset = %d, want 8", offset)
+	}
+
+	updates, stop = pollUpdates(bot, tgbotapi.UpdateConfig{Offset: 8, Timeout: 30})
+	if got := <-client.offsets; got != "8" {
+		t.Errorf("offset param = %q, want 8", got)
+	}
+	// Второй запрос висит в long poll, остановка должна его прервать
+	if offset := stopWithin(t, stop); offset != 8 {
+		t.Errorf("offset = %d, want 8", offset)
+	}
+	if _, ok := <-updates; ok {
+		t.Error("updates channel is not closed after stop")
+	}
+}
+
+// stopWithin останавливает опрос и проверяет, что остановка не ждёт окончания long poll
+func stopWithin(t *testing.T, stop func() int) int {
+	t.Helper()
+	stopped := make(chan int, 1)
+	go func() { stopped <- stop() }()
+	select {
+	case offset := <-stopped:
+		return offset
+	case <-time.After(time.Second):
+		t.Fatal("stop did not interrupt the pending getUpdates")
+		return 0
+	}
+}
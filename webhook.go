@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookConfig — параметры режима вебхука
+type webhookConfig struct {
+	URL     string `yaml:"url"`      // публичный адрес, который регистрируется в Telegram
+	Listen  string `yaml:"listen"`   // адрес локального HTTP-сервера
+	Secret  string `yaml:"secret"`   // значение заголовка X-Telegram-Bot-Api-Secret-Token; если пусто, генерируется случайное
+	TLSCert string `yaml:"tls_cert"` // путь к сертификату; без него сервер работает по HTTP (например, за reverse proxy)
+	TLSKey  string `yaml:"tls_key"`
+}
+
+// webhookMaxBody — наибольший размер тела запроса вебхука; обновления Telegram
+// занимают единицы килобайт
+const webhookMaxBody = 1 << 20
+
+// startWebhook поднимает HTTP(S)-сервер для приёма обновлений и регистрирует
+// вебхук в Telegram. Возвращённая функция останавливает сервер и удаляет вебхук.
+func startWebhook(bot *tgbotapi.BotAPI, cfg webhookConfig) (<-chan botUpdate, func(), error) {
+	u, err := neturl.Parse(cfg.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, nil, fmt.Errorf("некорректный адрес вебхука %q: Telegram принимает только https", cfg.URL)
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, nil, fmt.Errorf("для TLS нужно указать и сертификат, и ключ")
+	}
+
+	if cfg.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, fmt.Errorf("не удалось сгенерировать секрет вебхука: %v", err)
+		}
+		cfg.Secret = hex.EncodeToString(secret)
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	updates := make(chan botUpdate, bot.Buffer)
+
+	// Контекст запросов отменяется при остановке, чтобы обработчики,
+	// ожидающие основной цикл, не задерживали Shutdown
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+
+	mux := http.NewServeMux()
+	mux.Handle(path, webhookHandler(cfg.Secret, updates))
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+
+	go func() {
+		var err error
+		if cfg.TLSCert != "" {
+			err = server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Ошибка HTTP-сервера вебхука", "error", err)
+		}
+	}()
+
+	if err := setWebhook(bot, cfg); err != nil {
+		cancelRequests()
+		server.Close()
+		return nil, nil, err
+	}
+	slog.Info("Вебхук зарегистрирован", "url", cfg.URL, "listen", cfg.Listen)
+
+	stop := func() {
+		if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			slog.Error("Не удалось удалить вебхук", "error", err)
+		}
+		cancelRequests()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Ошибка остановки HTTP-сервера вебхука", "error", err)
+		}
+	}
+
+	return updates, stop, nil
+}
+
+// webhookHandler принимает обновления от Telegram и передаёт их в updates.
+// Если основной цикл не забрал обновление, пока запрос не отменён, Telegram
+// получает 503 и повторит доставку позже.
+func webhookHandler(secret string, updates chan<- botUpdate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			slog.Warn("Вебхук: отклонён запрос с неверным секретом", "remote", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update botUpdate
+		body := http.MaxBytesReader(w, r.Body, webhookMaxBody)
+		if err := json.NewDecoder(body).Decode(&update); err != nil {
+			slog.Warn("Вебхук: не удалось разобрать обновление", "error", err)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			slog.Warn("Вебхук: обновление не принято, основной цикл занят", "update_id", update.UpdateID)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// setWebhook регистрирует вебхук с секретом. WebhookConfig из tgbotapi
+// не поддерживает secret_token, поэтому параметры собираются вручную.
+func setWebhook(bot *tgbotapi.BotAPI, cfg webhookConfig) error {
+	params := tgbotapi.Params{}
+	params["url"] = cfg.URL
+	params["secret_token"] = cfg.Secret
+
+	var err error
+	if cfg.TLSCert != "" {
+		// Сертификат загружается, чтобы Telegram принимал и самоподписанные сертификаты
+		files := []tgbotapi.RequestFile{{Name: "certificate", Data: tgbotapi.FilePath(cfg.TLSCert)}}
+		_, err = bot.UploadFiles("setWebhook", params, files)
+	} else {
+		_, err = bot.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось зарегистрировать вебхук: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	const secret = "secret"
+	update := `{"update_id":42,"message":{"message_id":1,"chat":{"id":1,"type":"private"}}}`
+
+	tests := []struct {
+		name      string
+		method    string
+		secret    string
+		body      string
+		cancelled bool // основной цикл не забирает обновление, запрос отменён
+		want      int
+	}{
+		{name: "accepted", method: http.MethodPost, secret: secret, body: update, want: http.StatusOK},
+		{name: "busy loop", method: http.MethodPost, secret: secret, body: update, cancelled: true, want: http.StatusServiceUnavailable},
+		{name: "wrong secret", method: http.MethodPost, secret: "other", body: update, want: http.StatusUnauthorized},
+		{name: "get", method: http.MethodGet, secret: secret, want: http.StatusMethodNotAllowed},
+		{name: "malformed", method: http.MethodPost, secret: secret, body: "{", want: http.StatusBadRequest},
+		{name: "oversized", method: http.MethodPost, secret: secret,
+			body: `{"update_id":1,"message":{"text":"` + strings.Repeat("a", webhookMaxBody) + `"}}`, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := make(chan botUpdate, 1)
+			if tt.cancelled {
+				updates = make(chan botUpdate)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelled {
+				cancel()
+			}
+			defer cancel()
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)).WithContext(ctx)
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.secret)
+			rec := httptest.NewRecorder()
+			webhookHandler(secret, updates)(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				if got := <-updates; got.UpdateID != 42 {
+					t.Errorf("update_id = %d, want 42", got.UpdateID)
+				}
+			}
+		})
+	}
+}